@@ -0,0 +1,213 @@
+package availability
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/aws-alb/availability"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+		({{ .stepSum }}sum(
+			sum_over_time(aws_alb_httpcode_target_5_xx_count_sum{ {{ .filter }}load_balancer=~"{{ .loadBalancer }}", target_group=~"{{ .targetGroup }}" }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} OR on() vector(0))
+{{- if .countElb5xx }}
+		+
+		({{ .stepSum }}sum(
+			sum_over_time(aws_alb_httpcode_elb_5_xx_count_sum{ {{ .filter }}load_balancer=~"{{ .loadBalancer }}" }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} OR on() vector(0))
+{{- end }}
+	)
+	/
+	({{ .stepSum }}sum(
+		sum_over_time(aws_alb_request_count_sum{ {{ .filter }}load_balancer=~"{{ .loadBalancer }}", target_group=~"{{ .targetGroup }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the availability error based on AWS ALB metrics of the CloudWatch
+// exporter. These are gauges holding the sum of every CloudWatch period, so they are summed over the window instead
+// of using rate(). CloudWatch only has the ELB generated 5xx per load balancer, so they can't be counted together
+// with a target group, the ratio could exceed 1 as the requests would only be the ones of the target group.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	loadBalancer, err := getLoadBalancer(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get load balancer: %w", err)
+	}
+
+	targetGroup, err := getTargetGroup(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get target group: %w", err)
+	}
+
+	countElb5xx, err := getCountElb5xx(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get count ELB 5xx: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]interface{}{
+		"filter":       getFilter(options),
+		"loadBalancer": loadBalancer,
+		"targetGroup":  targetGroup,
+		"countElb5xx":  countElb5xx,
+	}
+	for k, v := range getMaintenanceSteps(options, offset) {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getFilter(options map[string]string) string {
+	filter := options["filter"]
+	filter = strings.Trim(filter, "{},")
+	if filter != "" {
+		filter += ","
+	}
+
+	return filter
+}
+
+func getLoadBalancer(options map[string]string) (string, error) {
+	loadBalancer := options["load_balancer_regex"]
+	loadBalancer = strings.TrimSpace(loadBalancer)
+
+	loadBalancers := options["load_balancers"]
+	loadBalancers = strings.TrimSpace(loadBalancers)
+
+	if loadBalancer != "" && loadBalancers != "" {
+		return "", fmt.Errorf("only one of load_balancer_regex and load_balancers can be set")
+	}
+
+	if loadBalancers != "" {
+		return getExactMatchRegex("load_balancers", loadBalancers)
+	}
+
+	if loadBalancer == "" {
+		return "", fmt.Errorf("load balancer is required")
+	}
+
+	_, err := regexp.Compile(loadBalancer)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return loadBalancer, nil
+}
+
+func getTargetGroup(options map[string]string) (string, error) {
+	targetGroup := options["target_group_regex"]
+	targetGroup = strings.TrimSpace(targetGroup)
+
+	if targetGroup == "" {
+		return ".*", nil
+	}
+
+	_, err := regexp.Compile(targetGroup)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return targetGroup, nil
+}
+
+func getCountElb5xx(options map[string]string) (bool, error) {
+	countElb5xx := options["count_elb_5xx"]
+	countElb5xx = strings.TrimSpace(countElb5xx)
+
+	if countElb5xx == "" {
+		return false, nil
+	}
+
+	value, err := strconv.ParseBool(countElb5xx)
+	if err != nil {
+		return false, fmt.Errorf("not a valid bool: %w", err)
+	}
+
+	if value && strings.TrimSpace(options["target_group_regex"]) != "" {
+		return false, fmt.Errorf("count_elb_5xx can't be set together with target_group_regex")
+	}
+
+	return value, nil
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is
+// set, so only the steps in which it is present are dropped instead of the whole window. Every step sums the samples
+// of its own minute, a longer range would count the CloudWatch periods more than once. The offset moves to the steps
+// to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) map[string]string {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": "1m",
+		"offset":     "",
+	}
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
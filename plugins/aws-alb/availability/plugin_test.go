@@ -0,0 +1,118 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/aws-alb/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without load balancer, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Both load balancer regex and load balancer list provided, should fail.": {
+			options: map[string]string{
+				"load_balancer_regex": "app/ota-live/.*",
+				"load_balancers":      "app/ota-live/50dc6c495c0c9188",
+			},
+			expErr: true,
+		},
+
+		"An invalid target group regex, should fail.": {
+			options: map[string]string{
+				"load_balancer_regex": "app/ota-live/.*",
+				"target_group_regex":  "([xyz",
+			},
+			expErr: true,
+		},
+
+		"An invalid count ELB 5xx flag, should fail.": {
+			options: map[string]string{
+				"load_balancer_regex": "app/ota-live/.*",
+				"count_elb_5xx":       "yes",
+			},
+			expErr: true,
+		},
+
+		"Count ELB 5xx provided together with a target group, should fail.": {
+			options: map[string]string{
+				"load_balancer_regex": "app/ota-live/.*",
+				"target_group_regex":  "targetgroup/ota-api/.*",
+				"count_elb_5xx":       "true",
+			},
+			expErr: true,
+		},
+
+		"Load balancer provided should return the ratio of target 5xx.": {
+			options: map[string]string{
+				"load_balancer_regex": "app/ota-live/.*",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			sum_over_time(aws_alb_httpcode_target_5_xx_count_sum{ load_balancer=~"app/ota-live/.*", target_group=~".*" }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		sum_over_time(aws_alb_request_count_sum{ load_balancer=~"app/ota-live/.*", target_group=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Count ELB 5xx provided should add the 5xx generated by the load balancer.": {
+			options: map[string]string{
+				"load_balancers":     "app/ota-live/50dc6c495c0c9188",
+				"count_elb_5xx":      "true",
+				"filter":             `{region="eu-west-1"}`,
+				"maintenance_series": `maintenance_mode{app="ota"}`,
+				"offset":             "10m",
+			},
+			expQuery: `
+(
+	(
+		(sum_over_time((sum(
+			sum_over_time(aws_alb_httpcode_target_5_xx_count_sum{ region="eu-west-1",load_balancer=~"app/ota-live/50dc6c495c0c9188", target_group=~".*" }[1m])
+		) unless on() (maintenance_mode{app="ota"}))[{{ .window }}:1m] offset 10m) OR on() vector(0))
+		+
+		(sum_over_time((sum(
+			sum_over_time(aws_alb_httpcode_elb_5_xx_count_sum{ region="eu-west-1",load_balancer=~"app/ota-live/50dc6c495c0c9188" }[1m])
+		) unless on() (maintenance_mode{app="ota"}))[{{ .window }}:1m] offset 10m) OR on() vector(0))
+	)
+	/
+	(sum_over_time((sum(
+		sum_over_time(aws_alb_request_count_sum{ region="eu-west-1",load_balancer=~"app/ota-live/50dc6c495c0c9188", target_group=~".*" }[1m])
+	) unless on() (maintenance_mode{app="ota"}))[{{ .window }}:1m] offset 10m) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package certexpiry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/cert-expiry"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+avg_over_time(
+	(
+		max({{ .metricName }}{{"{"}}{{ .additionalLabels }}{{ .ingressLabelName }}=~"{{ .ingressLabelValue }}"{{"}"}} - time() < bool {{ .expiryHorizon }}){{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the fraction of minutes in which a certificate expired within the
+// horizon based on blackbox exporter probe metrics.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	ingressLabelName, err := getIngressLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	ingressLabelValue, err := getIngressLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	expiryHorizon, err := getExpiryHorizon(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"metricName":        metricName,
+		"ingressLabelName":  ingressLabelName,
+		"ingressLabelValue": ingressLabelValue,
+		"expiryHorizon":     expiryHorizon,
+		"additionalLabels":  getAdditionalLabels(options),
+		"maintenance":       getMaintenanceFilter(options),
+		"offset":            offset,
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getIngressLabelName(options map[string]string) (string, error) {
+	label := options["ingressLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'ingressLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+func getIngressLabelValue(options map[string]string) (string, error) {
+	value := options["ingressLabelValue"]
+	value = strings.TrimSpace(value)
+
+	values := options["ingresses"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'ingressLabelValue' and 'ingresses' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("ingresses", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'ingressLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'ingressLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+// getMetricName returns the expiry of the earliest certificate the target presented, or with 'lastChainExpiry' the
+// expiry of the verified chain that lasts longest, which ignores certificates a client doesn't need.
+func getMetricName(options map[string]string) (string, error) {
+	lastChainExpiry := options["lastChainExpiry"]
+	lastChainExpiry = strings.TrimSpace(lastChainExpiry)
+
+	if lastChainExpiry == "" {
+		return "probe_ssl_earliest_cert_expiry", nil
+	}
+
+	enabled, err := strconv.ParseBool(lastChainExpiry)
+	if err != nil {
+		return "", fmt.Errorf("not a valid 'lastChainExpiry', can't parse to bool: %w", err)
+	}
+
+	if enabled {
+		return "probe_ssl_last_chain_expiry_timestamp_seconds", nil
+	}
+
+	return "probe_ssl_earliest_cert_expiry", nil
+}
+
+// getExpiryHorizon takes the horizon as a duration and returns it in seconds, the unit of the expiry timestamps.
+func getExpiryHorizon(options map[string]string) (string, error) {
+	horizon := options["expiryHorizon"]
+	horizon = strings.TrimSpace(horizon)
+
+	if horizon == "" {
+		return "", fmt.Errorf("'expiryHorizon' is required")
+	}
+
+	seconds, ok := durationSeconds(horizon)
+	if !ok || seconds <= 0 {
+		return "", fmt.Errorf("invalid duration for 'expiryHorizon': %q", horizon)
+	}
+
+	return strconv.FormatFloat(seconds, 'f', -1, 64), nil
+}
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+// getOffset shifts the whole subquery, time() is evaluated at the shifted time as well.
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
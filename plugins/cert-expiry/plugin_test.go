@@ -0,0 +1,100 @@
+package certexpiry_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	certexpiry "github.com/lokalise/common-sloth-sli-plugins/plugins/cert-expiry"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without expiry horizon, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "ota-.*",
+			},
+			expErr: true,
+		},
+
+		"An invalid expiry horizon, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "ota-.*",
+				"expiryHorizon":     "two weeks",
+			},
+			expErr: true,
+		},
+
+		"An invalid last chain expiry flag, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "ota-.*",
+				"expiryHorizon":     "14d",
+				"lastChainExpiry":   "yes",
+			},
+			expErr: true,
+		},
+
+		"Expiry horizon provided should mark minutes in which a certificate expires within it as bad.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "ota-.*",
+				"expiryHorizon":     "14d",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		max(probe_ssl_earliest_cert_expiry{ingress=~"ota-.*"} - time() < bool 1209600)
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+
+		"Last chain expiry provided should use the expiry of the longest lasting chain.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingresses":         "ota-custom-domains",
+				"expiryHorizon":     "7d",
+				"lastChainExpiry":   "true",
+				"additionalLabels":  `env="live"`,
+				"maintenanceSeries": `maintenance_mode{app="ota"}`,
+				"offset":            "5m",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		max(probe_ssl_last_chain_expiry_timestamp_seconds{env="live", ingress=~"ota-custom-domains"} - time() < bool 604800) unless on() (maintenance_mode{app="ota"})
+	)[{{ .window }}:1m] offset 5m
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := certexpiry.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
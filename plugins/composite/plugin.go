@@ -0,0 +1,266 @@
+package composite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/composite"
+)
+
+const (
+	combinationWeightedAverage = "weightedAverage"
+	combinationWorst           = "worst"
+	combinationAllGood         = "allGood"
+)
+
+var weightedAverageQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+{{- range $i, $sli := .slis }}{{ if $i }}
+		+ on(){{ end }}
+		{{ $sli.Weight }} * (
+			{{ $sli.Query }}
+		)
+{{- end }}
+	) / {{ .totalWeight }}
+) OR on() vector(0)
+`))
+
+var worstQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	max(
+{{- range $i, $sli := .slis }}{{ if $i }}
+		or{{ end }}
+		label_replace(
+			{{ $sli.Query }},
+			"sli", "{{ $sli.Name }}", "", ""
+		)
+{{- end }}
+	)
+) OR on() vector(0)
+`))
+
+var allGoodQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	1 - (
+{{- range $i, $sli := .slis }}{{ if $i }}
+		* on(){{ end }}
+		(1 - (
+			{{ $sli.Query }}
+		))
+{{- end }}
+	)
+) OR on() vector(0)
+`))
+
+var sliNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// pluginQueryShapes maps the IDs of the plugins a sub SLI can be based on to a pattern that the queries they render
+// always match, so hand written queries or queries pasted for another plugin are rejected. The patterns follow the
+// templates of those plugins and have to be updated together with them.
+var pluginQueryShapes = map[string]*regexp.Regexp{
+	"lokalise/aws-alb/availability":             regexp.MustCompile(`(?s)aws_alb_httpcode_target_5_xx_count_sum\{.*aws_alb_request_count_sum\{`),
+	"lokalise/cert-expiry":                      regexp.MustCompile(`(?s)^avg_over_time\(\s*\(\s*max\(.* - time\(\) < bool `),
+	"lokalise/freshness":                        regexp.MustCompile(`(?s)^avg_over_time\(\s*\(\s*\((max|min)\(time\(\) - .* > bool .*\) or on\(\) vector\(1\)\)`),
+	"lokalise/grpc/availability":                regexp.MustCompile(`(?s)grpc_service=~".*", grpc_method=~".*", grpc_code=~"`),
+	"lokalise/grpc/latency":                     regexp.MustCompile(`(?s)_bucket\{.*grpc_service=~".*", grpc_method=~".*", grpc_type=~".*", le="`),
+	"lokalise/http-error-rate":                  regexp.MustCompile(`(?s)^\(\s*\(.*\) AND on\([^)]*\) (avg_over_time\(\()?sum(\(| by)`),
+	"lokalise/http-latency":                     regexp.MustCompile(`(?s)^1 - \(\(.*le=".*\) AND on\([^)]*\) .*OR on\(\) vector\(1\)\)$`),
+	"lokalise/http-tenant-error-rate":           regexp.MustCompile(`(?s)^(max|\(\s*count)\(\s*\(\s*(sum_over_time\(\()?sum by \(`),
+	"lokalise/http/apdex":                       regexp.MustCompile(`(?s)^1 - \(\s*\(.*le=".*\+.*le=".*/\s*\(2 \* `),
+	"lokalise/http/availability":                regexp.MustCompile(`(?s)^\(\s*.*_count\{.*/\s*\((sum_over_time\(\()?sum\(\s*rate\([a-zA-Z_:]*_count\{.*\) > 0\)\s*\) OR on\(\) vector\(0\)$`),
+	"lokalise/http/latency":                     regexp.MustCompile(`(?s)^1 - \(\s*(sum_over_time\(\()?sum\(\s*rate\(.*le=".*/\s*\((sum_over_time\(\()?sum\(`),
+	"lokalise/istio/availability":               regexp.MustCompile(`(?s)istio_requests_total\{`),
+	"lokalise/istio/latency":                    regexp.MustCompile(`(?s)istio_request_duration_milliseconds_bucket\{`),
+	"lokalise/jobs/deadline":                    regexp.MustCompile(`(?s)increase\([a-zA-Z_:]*_count\{.*increase\([a-zA-Z_:]*_bucket\{.*le="`),
+	"lokalise/jobs/success":                     regexp.MustCompile(`(?s)^\(\s*\((sum_over_time\(\()?sum\(.*/\s*\(\(.*\+.*\) > 0\)`),
+	"lokalise/kafka/lag":                        regexp.MustCompile(`(?s)kafka_consumergroup_lag\{`),
+	"lokalise/kubernetes/job-success":           regexp.MustCompile(`(?s)kube_job_status_failed\{.*kube_job_owner\{`),
+	"lokalise/kubernetes/workload-availability": regexp.MustCompile(`(?s)kube_(deployment|statefulset)_status_replicas_available\{`),
+	"lokalise/nginx-http/availability":          regexp.MustCompile(`(?s)nginx_ingress_controller_request_duration_seconds_count\{`),
+	"lokalise/nginx-http/latency":               regexp.MustCompile(`(?s)nginx_ingress_controller_request_duration_seconds_bucket\{`),
+	"lokalise/probe-latency":                    regexp.MustCompile(`(?s)probe_(http_)?duration_seconds\{.* > bool `),
+	"lokalise/redis/availability":               regexp.MustCompile(`(?s)redis_up\{.*redis_commands_failed_calls_total\{`),
+	"lokalise/redis/latency":                    regexp.MustCompile(`(?s)redis_commands_latencies_usec_bucket\{`),
+	"lokalise/spanmetrics/availability":         regexp.MustCompile(`(?s)service_name=~".*", span_name=~".*", span_kind=".*", status_code="STATUS_CODE_ERROR"`),
+	"lokalise/spanmetrics/latency":              regexp.MustCompile(`(?s)service_name=~".*", span_name=~".*", span_kind=".*", le="`),
+	"lokalise/traefik/availability":             regexp.MustCompile(`(?s)traefik_service_requests_total\{`),
+	"lokalise/traefik/latency":                  regexp.MustCompile(`(?s)traefik_service_request_duration_seconds_bucket\{`),
+	"lokalise/uptime":                           regexp.MustCompile(`(?s)^max\(avg_over_time\(\s*\(\s*avg_over_time\(.*\[1m\]\) <= bool 0\.25`),
+}
+
+type subSLI struct {
+	Name   string
+	Plugin string
+	Query  string
+	Weight string
+}
+
+// SLIPlugin will return a query that combines the error ratios of several sub SLIs into a single one.
+//
+// Sloth loads every plugin on its own, so the sub SLIs can't be rendered here from a plugin ID and its options.
+// Every sub SLI references the ID of the plugin it is based on and is given as the error ratio query rendered by that
+// plugin, keeping the `{{ .window }}` placeholder so that it is rendered with the same window as the composite. The
+// query has to match the shape of the output of the referenced plugin.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	combination, err := getCombination(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	slis, err := getSubSLIs(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	data := map[string]interface{}{
+		"slis": slis,
+	}
+
+	var queryTpl *template.Template
+	switch combination {
+	case combinationWeightedAverage:
+		totalWeight := 0.0
+		for _, sli := range slis {
+			weight, _ := strconv.ParseFloat(sli.Weight, 64)
+			totalWeight += weight
+		}
+
+		data["totalWeight"] = strconv.FormatFloat(totalWeight, 'f', -1, 64)
+		queryTpl = weightedAverageQueryTpl
+	case combinationWorst:
+		queryTpl = worstQueryTpl
+	case combinationAllGood:
+		queryTpl = allGoodQueryTpl
+	}
+
+	var b bytes.Buffer
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getCombination(options map[string]string) (string, error) {
+	combination := options["combination"]
+	combination = strings.TrimSpace(combination)
+
+	switch combination {
+	case "":
+		return "", fmt.Errorf("'combination' is required")
+	case combinationWeightedAverage, combinationWorst, combinationAllGood:
+		return combination, nil
+	}
+
+	return "", fmt.Errorf("invalid 'combination' %q, must be %q, %q or %q", combination, combinationWeightedAverage, combinationWorst, combinationAllGood)
+}
+
+func getSubSLIs(options map[string]string) ([]subSLI, error) {
+	names := options["slis"]
+	names = strings.TrimSpace(names)
+
+	if names == "" {
+		return nil, fmt.Errorf("'slis' is required")
+	}
+
+	var slis []subSLI
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+
+		if !sliNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid sub SLI name %q in 'slis'", name)
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("duplicated sub SLI name %q in 'slis'", name)
+		}
+		seen[name] = true
+
+		plugin, err := getSubSLIPlugin(options, name)
+		if err != nil {
+			return nil, err
+		}
+
+		query, err := getSubSLIQuery(options, name, plugin)
+		if err != nil {
+			return nil, err
+		}
+
+		weight, err := getSubSLIWeight(options, name)
+		if err != nil {
+			return nil, err
+		}
+
+		slis = append(slis, subSLI{Name: name, Plugin: plugin, Query: query, Weight: weight})
+	}
+
+	return slis, nil
+}
+
+func getSubSLIPlugin(options map[string]string, name string) (string, error) {
+	key := name + ".plugin"
+	plugin := options[key]
+	plugin = strings.TrimSpace(plugin)
+
+	if plugin == "" {
+		return "", fmt.Errorf("'%s' is required", key)
+	}
+
+	if _, ok := pluginQueryShapes[plugin]; !ok {
+		return "", fmt.Errorf("unknown plugin %q in '%s'", plugin, key)
+	}
+
+	return plugin, nil
+}
+
+func getSubSLIQuery(options map[string]string, name, plugin string) (string, error) {
+	key := name + ".query"
+	query := options[key]
+	query = strings.TrimSpace(query)
+
+	if query == "" {
+		return "", fmt.Errorf("'%s' is required", key)
+	}
+
+	if !strings.Contains(query, "{{ .window }}") {
+		return "", fmt.Errorf("'%s' must use the '{{ .window }}' placeholder", key)
+	}
+
+	if !pluginQueryShapes[plugin].MatchString(query) {
+		return "", fmt.Errorf("'%s' is not a query rendered by the %q plugin", key, plugin)
+	}
+
+	return query, nil
+}
+
+func getSubSLIWeight(options map[string]string, name string) (string, error) {
+	key := name + ".weight"
+	weight := options[key]
+	weight = strings.TrimSpace(weight)
+
+	if weight == "" {
+		return "1", nil
+	}
+
+	value, err := strconv.ParseFloat(weight, 64)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid number: %w", key, err)
+	}
+
+	if value <= 0 {
+		return "", fmt.Errorf("'%s' must be greater than 0", key)
+	}
+
+	return weight, nil
+}
@@ -0,0 +1,413 @@
+package composite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	albavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/aws-alb/availability"
+	certexpiry "github.com/lokalise/common-sloth-sli-plugins/plugins/cert-expiry"
+	composite "github.com/lokalise/common-sloth-sli-plugins/plugins/composite"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/freshness"
+	grpcavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/grpc/availability"
+	grpclatency "github.com/lokalise/common-sloth-sli-plugins/plugins/grpc/latency"
+	errorrate "github.com/lokalise/common-sloth-sli-plugins/plugins/http-error-rate"
+	httplatencyguard "github.com/lokalise/common-sloth-sli-plugins/plugins/http-latency"
+	tenanterrorrate "github.com/lokalise/common-sloth-sli-plugins/plugins/http-tenant-error-rate"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/http/apdex"
+	httpavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/http/availability"
+	httplatency "github.com/lokalise/common-sloth-sli-plugins/plugins/http/latency"
+	istioavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/istio/availability"
+	istiolatency "github.com/lokalise/common-sloth-sli-plugins/plugins/istio/latency"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/jobs/deadline"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/jobs/success"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/kafka/lag"
+	jobsuccess "github.com/lokalise/common-sloth-sli-plugins/plugins/kubernetes/job-success"
+	workloadavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/kubernetes/workload-availability"
+	nginxavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/nginx-http/availability"
+	nginxlatency "github.com/lokalise/common-sloth-sli-plugins/plugins/nginx-http/latency"
+	probelatency "github.com/lokalise/common-sloth-sli-plugins/plugins/probe-latency"
+	redisavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/redis/availability"
+	redislatency "github.com/lokalise/common-sloth-sli-plugins/plugins/redis/latency"
+	spanavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/spanmetrics/availability"
+	spanlatency "github.com/lokalise/common-sloth-sli-plugins/plugins/spanmetrics/latency"
+	traefikavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/traefik/availability"
+	traefiklatency "github.com/lokalise/common-sloth-sli-plugins/plugins/traefik/latency"
+	"github.com/lokalise/common-sloth-sli-plugins/plugins/uptime"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An unknown combination, should fail.": {
+			options: map[string]string{
+				"combination":   "avg",
+				"slis":          "upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+			},
+			expErr: true,
+		},
+
+		"Without sub SLIs, should fail.": {
+			options: map[string]string{"combination": "worst"},
+			expErr:  true,
+		},
+
+		"An invalid sub SLI name, should fail.": {
+			options: map[string]string{
+				"combination":        "worst",
+				"slis":               "upload file",
+				"upload file.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload file.plugin": "lokalise/traefik/availability",
+			},
+			expErr: true,
+		},
+
+		"A duplicated sub SLI name, should fail.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload,upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+			},
+			expErr: true,
+		},
+
+		"A sub SLI without query, should fail.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload,import",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"import.plugin": "lokalise/istio/availability",
+			},
+			expErr: true,
+		},
+
+		"A sub SLI without plugin, should fail.": {
+			options: map[string]string{
+				"combination":  "worst",
+				"slis":         "upload",
+				"upload.query": `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+			},
+			expErr: true,
+		},
+
+		"A sub SLI with an unknown plugin, should fail.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload",
+				"upload.plugin": "lokalise/upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+			},
+			expErr: true,
+		},
+
+		"A sub SLI query not rendered by its plugin, should fail.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload",
+				"upload.plugin": "lokalise/istio/availability",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+			},
+			expErr: true,
+		},
+
+		"A sub SLI query without the window placeholder, should fail.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[5m])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[5m])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+			},
+			expErr: true,
+		},
+
+		"A non numeric sub SLI weight, should fail.": {
+			options: map[string]string{
+				"combination":   "weightedAverage",
+				"slis":          "upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"upload.weight": "heavy",
+			},
+			expErr: true,
+		},
+
+		"A zero sub SLI weight, should fail.": {
+			options: map[string]string{
+				"combination":   "weightedAverage",
+				"slis":          "upload",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"upload.weight": "0",
+			},
+			expErr: true,
+		},
+
+		"Weighted average combination should return a valid query.": {
+			options: map[string]string{
+				"combination":   "weightedAverage",
+				"slis":          "upload, import",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"upload.weight": "2",
+				"import.query":  `(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"import.plugin": "lokalise/istio/availability",
+			},
+			expQuery: `
+(
+	(
+		2 * (
+			(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)
+		)
+		+ on()
+		1 * (
+			(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0)
+		)
+	) / 3
+) OR on() vector(0)
+`,
+		},
+
+		"Worst combination should return a valid query.": {
+			options: map[string]string{
+				"combination":   "worst",
+				"slis":          "upload,import",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"import.query":  `(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"import.plugin": "lokalise/istio/availability",
+			},
+			expQuery: `
+(
+	max(
+		label_replace(
+			(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0),
+			"sli", "upload", "", ""
+		)
+		or
+		label_replace(
+			(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0),
+			"sli", "import", "", ""
+		)
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"All good combination should return a valid query.": {
+			options: map[string]string{
+				"combination":   "allGood",
+				"slis":          "upload,import",
+				"upload.query":  `(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"upload.plugin": "lokalise/traefik/availability",
+				"import.query":  `(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0)`,
+				"import.plugin": "lokalise/istio/availability",
+			},
+			expQuery: `
+(
+	1 - (
+		(1 - (
+			(sum(rate(traefik_service_requests_total{ service=~"upload-api", code=~"5.." }[{{ .window }}])) / (sum(rate(traefik_service_requests_total{ service=~"upload-api" }[{{ .window }}])) > 0)) OR on() vector(0)
+		))
+		* on()
+		(1 - (
+			(sum(rate(istio_requests_total{ destination_workload=~"import-worker", response_code=~"5.." }[{{ .window }}])) / (sum(rate(istio_requests_total{ destination_workload=~"import-worker" }[{{ .window }}])) > 0)) OR on() vector(0)
+		))
+	)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := composite.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
+
+// TestSLIPluginSubSLIShapes renders a query with every plugin a sub SLI can be based on and checks that the composite
+// accepts it, so the shapes can't drift away from the templates of the plugins.
+func TestSLIPluginSubSLIShapes(t *testing.T) {
+	type sliPlugin func(ctx context.Context, meta, labels, options map[string]string) (string, error)
+
+	tests := map[string]struct {
+		plugin  sliPlugin
+		options map[string]string
+	}{
+		albavailability.SLIPluginID: {
+			plugin:  albavailability.SLIPlugin,
+			options: map[string]string{"load_balancer_regex": "app/ota-live/.*"},
+		},
+		certexpiry.SLIPluginID: {
+			plugin:  certexpiry.SLIPlugin,
+			options: map[string]string{"ingressLabelName": "ingress", "ingressLabelValue": "ota-.*", "expiryHorizon": "14d"},
+		},
+		freshness.SLIPluginID: {
+			plugin:  freshness.SLIPlugin,
+			options: map[string]string{"metricName": "tm_sync_last_success_timestamp_seconds", "stalenessThreshold": "1h30m"},
+		},
+		grpcavailability.SLIPluginID: {
+			plugin:  grpcavailability.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files"},
+		},
+		grpclatency.SLIPluginID: {
+			plugin:  grpclatency.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files", "bucket": "0.5"},
+		},
+		errorrate.SLIPluginID: {
+			plugin: errorrate.SLIPlugin,
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "files",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "5..",
+				"minimumRequestsPerSecond": "10",
+			},
+		},
+		httplatencyguard.SLIPluginID: {
+			plugin: httplatencyguard.SLIPlugin,
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "files",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+			},
+		},
+		tenanterrorrate.SLIPluginID: {
+			plugin: tenanterrorrate.SLIPlugin,
+			options: map[string]string{
+				"metricName":                     "http_request_duration_seconds_count",
+				"serviceLabelName":               "service",
+				"serviceLabelValue":              "files",
+				"errorLabelName":                 "status_code",
+				"errorLabelValue":                "5..",
+				"tenantLabelName":                "team_id",
+				"minimumTenantRequestsPerSecond": "0.1",
+			},
+		},
+		apdex.SLIPluginID: {
+			plugin:  apdex.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files", "bucket": "0.25"},
+		},
+		httpavailability.SLIPluginID: {
+			plugin:  httpavailability.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files"},
+		},
+		httplatency.SLIPluginID: {
+			plugin:  httplatency.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files", "bucket": "0.5"},
+		},
+		istioavailability.SLIPluginID: {
+			plugin:  istioavailability.SLIPlugin,
+			options: map[string]string{"destination_workload_regex": "files-api"},
+		},
+		istiolatency.SLIPluginID: {
+			plugin:  istiolatency.SLIPlugin,
+			options: map[string]string{"destination_workload_regex": "files-api", "bucket": "0.3"},
+		},
+		deadline.SLIPluginID: {
+			plugin:  deadline.SLIPlugin,
+			options: map[string]string{"metricName": "bullmq_job_duration_seconds", "queueLabelName": "queue", "queueLabelValue": "file-export", "deadline": "120"},
+		},
+		success.SLIPluginID: {
+			plugin:  success.SLIPlugin,
+			options: map[string]string{"goodMetricName": "bullmq_jobs_completed_total", "badMetricName": "bullmq_jobs_failed_total", "queueLabelName": "queue", "queueLabelValue": "file-import"},
+		},
+		lag.SLIPluginID: {
+			plugin:  lag.SLIPlugin,
+			options: map[string]string{"consumerGroup": "import-worker", "lagThreshold": "1000"},
+		},
+		jobsuccess.SLIPluginID: {
+			plugin:  jobsuccess.SLIPlugin,
+			options: map[string]string{"namespace": "glossary", "cronJob": "glossary-sync"},
+		},
+		workloadavailability.SLIPluginID: {
+			plugin:  workloadavailability.SLIPlugin,
+			options: map[string]string{"namespace": "internal", "workload": "glossary-api", "requiredReplicas": "2"},
+		},
+		nginxavailability.SLIPluginID: {
+			plugin:  nginxavailability.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files"},
+		},
+		nginxlatency.SLIPluginID: {
+			plugin:  nginxlatency.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files", "bucket": "0.5"},
+		},
+		probelatency.SLIPluginID: {
+			plugin:  probelatency.SLIPlugin,
+			options: map[string]string{"ingressLabelName": "ingress", "ingressLabelValue": "files-ingress", "latencyThreshold": "0.5"},
+		},
+		redisavailability.SLIPluginID: {
+			plugin:  redisavailability.SLIPlugin,
+			options: map[string]string{},
+		},
+		redislatency.SLIPluginID: {
+			plugin:  redislatency.SLIPlugin,
+			options: map[string]string{"bucket": "0.001024"},
+		},
+		spanavailability.SLIPluginID: {
+			plugin:  spanavailability.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files-api"},
+		},
+		spanlatency.SLIPluginID: {
+			plugin:  spanlatency.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files-api", "bucket": "0.5"},
+		},
+		traefikavailability.SLIPluginID: {
+			plugin:  traefikavailability.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files-api"},
+		},
+		traefiklatency.SLIPluginID: {
+			plugin:  traefiklatency.SLIPlugin,
+			options: map[string]string{"service_name_regex": "files-api", "bucket": "0.3"},
+		},
+		uptime.SLIPluginID: {
+			plugin:  uptime.SLIPlugin,
+			options: map[string]string{"metricName": "probe_success", "ingressLabelName": "ingress", "ingressLabelValue": "files-ingress"},
+		},
+	}
+
+	for id, test := range tests {
+		t.Run(id, func(t *testing.T) {
+			assert := assert.New(t)
+
+			query, err := test.plugin(context.TODO(), nil, nil, test.options)
+			if !assert.NoError(err) {
+				return
+			}
+
+			_, err = composite.SLIPlugin(context.TODO(), nil, nil, map[string]string{
+				"combination": "worst",
+				"slis":        "sub",
+				"sub.plugin":  id,
+				"sub.query":   query,
+			})
+			assert.NoError(err)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package freshness
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/freshness"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+avg_over_time(
+	(
+		({{ .aggregation }}(time() - {{ .metricName }}{{"{"}}{{ .additionalLabels }}{{"}"}} > bool {{ .stalenessThreshold }}) or on() vector(1)){{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+) OR on() vector(0)
+`))
+
+// aggregations maps the aggregation option to the operator that merges the staleness of the matching series. With
+// "all" a minute is stale as soon as one series is stale, with "any" only when every series is stale.
+var aggregations = map[string]string{
+	"all": "max",
+	"any": "min",
+}
+
+// SLIPlugin will return a query that will return the fraction of minutes in which the data was stale based on last
+// success timestamp gauges. Minutes without any matching series count as stale too, as the timestamps disappear
+// when the exporter is down or the job is renamed.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	stalenessThreshold, err := getStalenessThreshold(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	aggregation, err := getAggregation(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"metricName":         metricName,
+		"stalenessThreshold": stalenessThreshold,
+		"aggregation":        aggregation,
+		"additionalLabels":   getAdditionalLabels(options),
+		"maintenance":        getMaintenanceFilter(options),
+		"offset":             offset,
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	return labels
+}
+
+func getMetricName(options map[string]string) (string, error) {
+	metricName := options["metricName"]
+	metricName = strings.TrimSpace(metricName)
+
+	if metricName == "" {
+		return "", fmt.Errorf("'metricName' is required")
+	}
+
+	if !metricNameRegexp.MatchString(metricName) {
+		return "", fmt.Errorf("invalid metric name for 'metricName': %q", metricName)
+	}
+
+	return metricName, nil
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// getStalenessThreshold takes the threshold as a duration and returns it in seconds, the unit of the timestamps.
+func getStalenessThreshold(options map[string]string) (string, error) {
+	threshold := options["stalenessThreshold"]
+	threshold = strings.TrimSpace(threshold)
+
+	if threshold == "" {
+		return "", fmt.Errorf("'stalenessThreshold' is required")
+	}
+
+	seconds, ok := durationSeconds(threshold)
+	if !ok || seconds <= 0 {
+		return "", fmt.Errorf("invalid duration for 'stalenessThreshold': %q", threshold)
+	}
+
+	return strconv.FormatFloat(seconds, 'f', -1, 64), nil
+}
+
+func getAggregation(options map[string]string) (string, error) {
+	aggregation := options["aggregation"]
+	aggregation = strings.TrimSpace(aggregation)
+
+	if aggregation == "" {
+		aggregation = "all"
+	}
+
+	operator, ok := aggregations[aggregation]
+	if !ok {
+		return "", fmt.Errorf("unknown aggregation %q, must be all or any", aggregation)
+	}
+
+	return operator, nil
+}
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+// getOffset shifts the whole subquery, time() is evaluated at the shifted time as well.
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
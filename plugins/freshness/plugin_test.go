@@ -0,0 +1,115 @@
+package freshness_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	freshness "github.com/lokalise/common-sloth-sli-plugins/plugins/freshness"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without staleness threshold, should fail.": {
+			options: map[string]string{"metricName": "tm_sync_last_success_timestamp_seconds"},
+			expErr:  true,
+		},
+
+		"An invalid staleness threshold, should fail.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "90 minutes",
+			},
+			expErr: true,
+		},
+
+		"A zero staleness threshold, should fail.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "0s",
+			},
+			expErr: true,
+		},
+
+		"An unknown aggregation, should fail.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "1h",
+				"aggregation":        "most",
+			},
+			expErr: true,
+		},
+
+		"Staleness threshold provided should mark minutes in which any series is stale or none exists as bad.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "1h30m",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		(max(time() - tm_sync_last_success_timestamp_seconds{} > bool 5400) or on() vector(1))
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+
+		"Any aggregation provided should only mark minutes in which every series is stale as bad.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "1500ms",
+				"aggregation":        "any",
+				"additionalLabels":   `{env="live", pipeline=~"glossary|tm"}`,
+			},
+			expQuery: `
+avg_over_time(
+	(
+		(min(time() - tm_sync_last_success_timestamp_seconds{env="live", pipeline=~"glossary|tm"} > bool 1.5) or on() vector(1))
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series and offset provided should be applied.": {
+			options: map[string]string{
+				"metricName":         "tm_sync_last_success_timestamp_seconds",
+				"stalenessThreshold": "1d",
+				"maintenanceSeries":  `maintenance_mode{app="tm-sync"}`,
+				"offset":             "5m",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		(max(time() - tm_sync_last_success_timestamp_seconds{} > bool 86400) or on() vector(1)) unless on() (maintenance_mode{app="tm-sync"})
+	)[{{ .window }}:1m] offset 5m
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := freshness.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
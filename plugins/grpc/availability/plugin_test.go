@@ -0,0 +1,110 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/grpc/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An invalid service name query, should fail.": {
+			options: map[string]string{"service_name_regex": "([xyz"},
+			expErr:  true,
+		},
+
+		"An invalid method query, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"method_regex":       "([xyz",
+			},
+			expErr: true,
+		},
+
+		"An unknown gRPC code, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"codes":              "Internal,ServerError",
+			},
+			expErr: true,
+		},
+
+		"A non numeric minimum requests per second, should fail.": {
+			options: map[string]string{
+				"service_name_regex":          "test",
+				"minimum_requests_per_second": "ten",
+			},
+			expErr: true,
+		},
+
+		"With service name should return a valid query with the default codes.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+			},
+			expQuery: `
+(
+	sum(
+		rate(grpc_server_handled_total{ grpc_service=~"test", grpc_method=~".*", grpc_code=~"Unknown|Internal|Unavailable|DeadlineExceeded|DataLoss|ResourceExhausted" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(grpc_server_handled_total{ grpc_service=~"test", grpc_method=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"All options provided should return a valid query.": {
+			options: map[string]string{
+				"filter":                      `{namespace="files"},`,
+				"services":                    "lokalise.files.v1.FileService",
+				"method_regex":                "(Upload|Download)",
+				"codes":                       "Internal, Unavailable",
+				"minimum_requests_per_second": "0.5",
+				"offset":                      "2m",
+				"maintenance_series":          `maintenance_active{service="files"} == 1`,
+			},
+			expQuery: `
+(
+	sum_over_time((sum(
+		rate(grpc_server_handled_total{ namespace="files",grpc_service=~"lokalise\\.files\\.v1\\.FileService", grpc_method=~"(Upload|Download)", grpc_code=~"Internal|Unavailable" }[5m])
+	) unless on() (maintenance_active{service="files"} == 1))[{{ .window }}:1m] offset 2m)
+	/
+	(sum_over_time((sum(
+		rate(grpc_server_handled_total{ namespace="files",grpc_service=~"lokalise\\.files\\.v1\\.FileService", grpc_method=~"(Upload|Download)" }[5m])
+	) unless on() (maintenance_active{service="files"} == 1))[{{ .window }}:1m] offset 2m) AND on() avg_over_time((sum(
+		rate(grpc_server_handled_total{ namespace="files",grpc_service=~"lokalise\\.files\\.v1\\.FileService", grpc_method=~"(Upload|Download)" }[5m])
+	) unless on() (maintenance_active{service="files"} == 1))[{{ .window }}:1m] offset 2m) > 0.5)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package latency
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/grpc/latency"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+1 - (
+	{{ .stepSum }}sum(
+		rate({{ .metric_name }}_bucket{ {{ .filter }}grpc_service=~"{{ .serviceName }}", grpc_method=~"{{ .method }}", grpc_type=~"{{ .grpcType }}", le="{{ .bucket }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
+	/
+	({{ .stepSum }}sum(
+		rate({{ .metric_name }}_count{ {{ .filter }}grpc_service=~"{{ .serviceName }}", grpc_method=~"{{ .method }}", grpc_type=~"{{ .grpcType }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
+{{- if .stepAvg }} AND on() {{ .stepAvg }}sum(
+		rate({{ .metric_name }}_count{ {{ .filter }}grpc_service=~"{{ .serviceName }}", grpc_method=~"{{ .method }}", grpc_type=~"{{ .grpcType }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
+{{- end }} > {{ .minimumRequestsPerSecond }})
+) OR on() vector(0)
+`))
+
+var grpcTypes = []string{"unary", "client_stream", "server_stream", "bidi_stream"}
+
+// SLIPlugin will return a query that will return the latency error based on go-grpc-prometheus server handling
+// histograms.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	service, err := getServiceName(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get service name: %w", err)
+	}
+
+	method, err := getMethod(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get method: %w", err)
+	}
+
+	grpcType, err := getGRPCType(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get gRPC type: %w", err)
+	}
+
+	bucket, err := getBucket(options)
+	if err != nil {
+		return "", fmt.Errorf(`could not get bucket: %w`, err)
+	}
+
+	minimumRequestsPerSecond, err := getMinimumRequestsPerSecond(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get minimum requests per second: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"metric_name":              getMetricName(options),
+		"filter":                   getFilter(options),
+		"serviceName":              service,
+		"method":                   method,
+		"grpcType":                 grpcType,
+		"bucket":                   bucket,
+		"minimumRequestsPerSecond": minimumRequestsPerSecond,
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getFilter(options map[string]string) string {
+	filter := options["filter"]
+	filter = strings.Trim(filter, "{},")
+	if filter != "" {
+		filter += ","
+	}
+
+	return filter
+}
+
+func getServiceName(options map[string]string) (string, error) {
+	service := options["service_name_regex"]
+	service = strings.TrimSpace(service)
+
+	services := options["services"]
+	services = strings.TrimSpace(services)
+
+	if service != "" && services != "" {
+		return "", fmt.Errorf("only one of service_name_regex and services can be set")
+	}
+
+	if services != "" {
+		return getExactMatchRegex("services", services)
+	}
+
+	if service == "" {
+		return "", fmt.Errorf("service name is required")
+	}
+
+	_, err := regexp.Compile(service)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return service, nil
+}
+
+func getMethod(options map[string]string) (string, error) {
+	method := options["method_regex"]
+	method = strings.TrimSpace(method)
+
+	if method == "" {
+		return ".*", nil
+	}
+
+	_, err := regexp.Compile(method)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return method, nil
+}
+
+func getGRPCType(options map[string]string) (string, error) {
+	grpcType := options["grpc_type"]
+	grpcType = strings.TrimSpace(grpcType)
+
+	if grpcType == "" {
+		return ".*", nil
+	}
+
+	var values []string
+	for _, t := range strings.Split(grpcType, ",") {
+		t = strings.TrimSpace(t)
+		if !isGRPCType(t) {
+			return "", fmt.Errorf("unknown gRPC type %q", t)
+		}
+
+		values = append(values, t)
+	}
+
+	return strings.Join(values, "|"), nil
+}
+
+func isGRPCType(grpcType string) bool {
+	for _, t := range grpcTypes {
+		if t == grpcType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getBucket(options map[string]string) (string, error) {
+	bucket := options["bucket"]
+	if bucket == "" {
+		return "", fmt.Errorf(`"bucket" option is required`)
+	}
+
+	_, err := strconv.ParseFloat(bucket, 64)
+	if err != nil {
+		return "", fmt.Errorf("not a valid bucket, can't parse to float64: %w", err)
+	}
+
+	return bucket, nil
+}
+
+func getMinimumRequestsPerSecond(options map[string]string) (string, error) {
+	minimumRequestsPerSecond := options["minimum_requests_per_second"]
+	minimumRequestsPerSecond = strings.TrimSpace(minimumRequestsPerSecond)
+
+	if minimumRequestsPerSecond == "" {
+		return "0", nil
+	}
+
+	_, err := strconv.ParseFloat(minimumRequestsPerSecond, 64)
+	if err != nil {
+		return "", fmt.Errorf("not a valid number, can't parse to float64: %w", err)
+	}
+
+	return minimumRequestsPerSecond, nil
+}
+
+func getMetricName(options map[string]string) string {
+	metricName := options["metric_name"]
+	if metricName == "" {
+		metricName = "grpc_server_handling_seconds"
+	}
+
+	return getHistogramBaseName(metricName)
+}
+
+// getHistogramBaseName strips the series suffix from a histogram metric name, so the base name or the name of any
+// of its series can be used.
+func getHistogramBaseName(metricName string) string {
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if strings.HasSuffix(metricName, suffix) {
+			return strings.TrimSuffix(metricName, suffix)
+		}
+	}
+
+	return metricName
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// Rates compared to the traffic guard are averaged over the steps instead of summed. The offset moves to the steps to
+// shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepAvg":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepAvg":    "avg_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
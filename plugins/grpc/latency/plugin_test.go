@@ -0,0 +1,121 @@
+package latency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	latency "github.com/lokalise/common-sloth-sli-plugins/plugins/grpc/latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{"bucket": "0.5"},
+			expErr:  true,
+		},
+
+		"Without bucket, should fail.": {
+			options: map[string]string{"service_name_regex": "test"},
+			expErr:  true,
+		},
+
+		"A non numeric bucket, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "500ms",
+			},
+			expErr: true,
+		},
+
+		"An unknown gRPC type, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"grpc_type":          "streaming",
+			},
+			expErr: true,
+		},
+
+		"With service name and bucket should return a valid query.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(grpc_server_handling_seconds_bucket{ grpc_service=~"test", grpc_method=~".*", grpc_type=~".*", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(grpc_server_handling_seconds_count{ grpc_service=~"test", grpc_method=~".*", grpc_type=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"gRPC type provided should exclude the other RPC types.": {
+			options: map[string]string{
+				"filter":                      `namespace="files"`,
+				"service_name_regex":          "lokalise\\.files\\..*",
+				"method_regex":                "Upload",
+				"grpc_type":                   "unary",
+				"bucket":                      "0.25",
+				"metric_name":                 "grpc_server_handling_seconds_bucket",
+				"minimum_requests_per_second": "1",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(grpc_server_handling_seconds_bucket{ namespace="files",grpc_service=~"lokalise\.files\..*", grpc_method=~"Upload", grpc_type=~"unary", le="0.25" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(grpc_server_handling_seconds_count{ namespace="files",grpc_service=~"lokalise\.files\..*", grpc_method=~"Upload", grpc_type=~"unary" }[{{ .window }}])
+	) > 1)
+) OR on() vector(0)
+`,
+		},
+
+		"Several gRPC types provided should be joined into an alternation.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"grpc_type":          "unary, client_stream",
+				"bucket":             "0.5",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(grpc_server_handling_seconds_bucket{ grpc_service=~"test", grpc_method=~".*", grpc_type=~"unary|client_stream", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(grpc_server_handling_seconds_count{ grpc_service=~"test", grpc_method=~".*", grpc_type=~"unary|client_stream" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := latency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -15,43 +16,151 @@ const (
 )
 
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+{{- if .errorWeights }}
+		(
+{{- range $i, $w := .errorWeights }}{{ if $i }}
+			+{{ end }}
+			{{ $w.Weight }} * ({{ $.stepSum }}sum(
+				rate({{ $.metricName }}{ {{ $.additionalLabels }}{{ $.serviceLabelName }}=~"{{ $.serviceLabelValue }}", {{ $.errorLabelName }}=~"{{ $w.Matcher }}"{{ if $w.Exclude }}, {{ $.errorLabelName }}!~"{{ $w.Exclude }}"{{ end }}}[{{ $.rateWindow }}]{{ $.offset }})
+			){{ $.stepEnd }} OR on() vector(0))
+{{- end }}
+		)
+{{- else }}
+		{{ .stepSum }}sum(
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", {{ .errorLabelName }}=~"{{ .errorLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+{{- end }}
+		/
+		({{ .stepSum }}sum(
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} > 0)
+	) AND on() {{ .stepAvg }}sum(rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})){{ .stepEnd }} > {{ .minimumRequestsPerSecond }}
+) OR on() vector(0)
+`))
+
+// groupedQueryTpl applies the traffic guard to every group on its own, only the groups above it are part of the ratio.
+var groupedQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 (
 	(
 		sum(
-			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", {{ .errorLabelName }}=~"{{ .errorLabelValue }}"}[{{"{{ .window }}"}}])
+{{- if .errorWeights }}
+			(
+{{- range $i, $w := .errorWeights }}{{ if $i }}
+				+{{ end }}
+				{{ $w.Weight }} * ({{ $.stepSum }}sum by ({{ $.trafficGuardGroupBy }}) (
+					rate({{ $.metricName }}{ {{ $.additionalLabels }}{{ $.serviceLabelName }}=~"{{ $.serviceLabelValue }}", {{ $.errorLabelName }}=~"{{ $w.Matcher }}"{{ if $w.Exclude }}, {{ $.errorLabelName }}!~"{{ $w.Exclude }}"{{ end }}}[{{ $.rateWindow }}]{{ $.offset }})
+				){{ $.stepEnd }} OR {{ $.stepSum }}sum by ({{ $.trafficGuardGroupBy }}) (
+					rate({{ $.metricName }}{ {{ $.additionalLabels }}{{ $.serviceLabelName }}=~"{{ $.serviceLabelValue }}"}[{{ $.rateWindow }}]{{ $.offset }})
+				){{ $.stepEnd }} * 0)
+{{- end }}
+			) AND on({{ .trafficGuardGroupBy }}) {{ .stepAvg }}sum by ({{ .trafficGuardGroupBy }}) (
+{{- else }}
+			{{ .stepSum }}sum by ({{ .trafficGuardGroupBy }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", {{ .errorLabelName }}=~"{{ .errorLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }} AND on({{ .trafficGuardGroupBy }}) {{ .stepAvg }}sum by ({{ .trafficGuardGroupBy }}) (
+{{- end }}
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }} > {{ .minimumRequestsPerSecond }}
 		)
 		/
 		(sum(
-			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{"{{ .window }}"}}])
+			{{ .stepSum }}sum by ({{ .trafficGuardGroupBy }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
+{{- if .stepAvg }} AND on({{ .trafficGuardGroupBy }}) {{ .stepAvg }}sum by ({{ .trafficGuardGroupBy }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
+{{- end }} > {{ .minimumRequestsPerSecond }}
 		) > 0)
-	) AND on() sum(rate({{ .metricName }}{ {{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{"{{ .window }}"}}])) > {{ .minimumRequestsPerSecond }}
+	)
 ) OR on() vector(0)
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 service metrics.
+type errorWeight struct {
+	Matcher string
+	Exclude string
+	Weight  string
+}
+
+// SLIPlugin will return a query that will return the availability error based on HTTP request metrics.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
 	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	serviceLabelName, err := getServiceLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	serviceLabelValue, err := getServiceLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	errorLabelName, err := getErrorLabelName(options)
-	errorLabelValue, err := getErrorLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	errorWeights, err := getErrorWeights(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	errorLabelValue := ""
+	if len(errorWeights) == 0 {
+		errorLabelValue, err = getErrorLabelValue(options)
+		if err != nil {
+			return "", fmt.Errorf("Error parsing options: %w", err)
+		}
+	}
+
 	minimumRequestsPerSecond, err := getMinimumRequestsPerSecond(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
 
+	trafficGuardGroupBy, err := getTrafficGuardGroupBy(options)
 	if err != nil {
 		return "", fmt.Errorf("Error parsing options: %w", err)
 	}
 
 	var b bytes.Buffer
-	data := map[string]string{
+	data := map[string]interface{}{
 		"metricName":               metricName,
 		"serviceLabelName":         serviceLabelName,
 		"serviceLabelValue":        serviceLabelValue,
 		"errorLabelName":           errorLabelName,
 		"errorLabelValue":          errorLabelValue,
+		"errorWeights":             errorWeights,
 		"additionalLabels":         getAdditionalLabels(options),
 		"minimumRequestsPerSecond": minimumRequestsPerSecond,
+		"trafficGuardGroupBy":      trafficGuardGroupBy,
 	}
-	err = queryTpl.Execute(&b, data)
+	for k, v := range steps {
+		data[k] = v
+	}
+
+	tpl := queryTpl
+	if trafficGuardGroupBy != "" {
+		tpl = groupedQueryTpl
+	}
+
+	err = tpl.Execute(&b, data)
 	if err != nil {
 		return "", fmt.Errorf("could not render query template: %w", err)
 	}
@@ -85,6 +194,17 @@ func getServiceLabelValue(options map[string]string) (string, error) {
 	value := options["serviceLabelValue"]
 	value = strings.TrimSpace(value)
 
+	values := options["services"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'serviceLabelValue' and 'services' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("services", values)
+	}
+
 	if value == "" {
 		return "", fmt.Errorf("'serviceLabelValue' is required")
 	}
@@ -124,6 +244,57 @@ func getErrorLabelValue(options map[string]string) (string, error) {
 	return value, nil
 }
 
+// getErrorWeights parses a comma separated list of `matcher:weight` pairs. Every error is counted with the weight of
+// the first matcher it matches, the earlier matchers are excluded from the later ones so overlapping matchers don't
+// count an error twice.
+func getErrorWeights(options map[string]string) ([]errorWeight, error) {
+	weights := options["errorWeights"]
+	weights = strings.TrimSpace(weights)
+
+	if weights == "" {
+		return nil, nil
+	}
+
+	if strings.TrimSpace(options["errorLabelValue"]) != "" {
+		return nil, fmt.Errorf("only one of 'errorLabelValue' and 'errorWeights' can be set")
+	}
+
+	var errorWeights []errorWeight
+	var matchers []string
+	for _, pair := range strings.Split(weights, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid 'errorWeights' entry %q, must be matcher:weight", pair)
+		}
+
+		matcher := strings.TrimSpace(pair[:i])
+		weight := strings.TrimSpace(pair[i+1:])
+
+		if matcher == "" {
+			return nil, fmt.Errorf("invalid 'errorWeights' entry %q, matcher is required", pair)
+		}
+
+		_, err := regexp.Compile(matcher)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for 'errorWeights': %w", err)
+		}
+
+		value, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'errorWeights' entry %q is not a valid number: %w", pair, err)
+		}
+
+		if value <= 0 || value > 1 {
+			return nil, fmt.Errorf("'errorWeights' entry %q must have a weight greater than 0 and at most 1", pair)
+		}
+
+		errorWeights = append(errorWeights, errorWeight{Matcher: matcher, Exclude: strings.Join(matchers, "|"), Weight: weight})
+		matchers = append(matchers, matcher)
+	}
+
+	return errorWeights, nil
+}
+
 func getMetricName(options map[string]string) (string, error) {
 	metricName := options["metricName"]
 	if metricName == "" {
@@ -135,9 +306,143 @@ func getMetricName(options map[string]string) (string, error) {
 
 func getMinimumRequestsPerSecond(options map[string]string) (string, error) {
 	minimumRequestsPerSecond := options["minimumRequestsPerSecond"]
+	minimumRequestsPerSecond = strings.TrimSpace(minimumRequestsPerSecond)
+
 	if minimumRequestsPerSecond == "" {
 		return "", fmt.Errorf("'minimumRequestsPerSecond' is required")
 	}
 
+	_, err := strconv.ParseFloat(minimumRequestsPerSecond, 64)
+	if err != nil {
+		return "", fmt.Errorf("'minimumRequestsPerSecond' is not a valid number: %w", err)
+	}
+
 	return minimumRequestsPerSecond, nil
 }
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func getTrafficGuardGroupBy(options map[string]string) (string, error) {
+	groupBy := options["trafficGuardGroupBy"]
+	groupBy = strings.TrimSpace(groupBy)
+
+	if groupBy == "" {
+		return "", nil
+	}
+
+	var labels []string
+	for _, label := range strings.Split(groupBy, ",") {
+		label = strings.TrimSpace(label)
+		if !labelNameRegexp.MatchString(label) {
+			return "", fmt.Errorf("invalid label name in 'trafficGuardGroupBy': %q", label)
+		}
+
+		labels = append(labels, label)
+	}
+
+	return strings.Join(labels, ", "), nil
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// Rates compared to the traffic guard are averaged over the steps instead of summed. The offset moves to the steps to
+// shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepAvg":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenanceRateWindow"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenanceRateWindow': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepAvg":    "avg_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
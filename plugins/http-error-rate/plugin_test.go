@@ -67,7 +67,320 @@ func TestSLIPlugin(t *testing.T) {
 		(sum(
 			rate(http_request_duration_seconds_count{ route=~".*", service=~"test"}[{{ .window }}])
 		) > 0)
-	) AND on(service) sum(rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])) > 10
+	) AND on() sum(rate(http_request_duration_seconds_count{ route=~".*", service=~"test"}[{{ .window }}])) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"A maintenance rate window shorter than the steps, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"maintenanceSeries":        `maintenance_active{service="test"} == 1`,
+				"maintenanceRateWindow":    "30s",
+			},
+			expErr: true,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"maintenanceSeries":        `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+(
+	(
+		sum_over_time((sum(
+			rate(http_request_duration_seconds_count{ service=~"test", status_code=~"(5..|429|431)"}[5m])
+		) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+		/
+		(sum_over_time((sum(
+			rate(http_request_duration_seconds_count{ service=~"test"}[5m])
+		) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+	) AND on() avg_over_time((sum(rate(http_request_duration_seconds_count{ service=~"test"}[5m])) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"offset":                   "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"offset":                   "5m",
+			},
+			expQuery: `
+(
+	(
+		sum(
+			rate(http_request_duration_seconds_count{ service=~"test", status_code=~"(5..|429|431)"}[{{ .window }}] offset 5m)
+		)
+		/
+		(sum(
+			rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}] offset 5m)
+		) > 0)
+	) AND on() sum(rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}] offset 5m)) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"serviceLabelValue":        "test",
+				"services":                 "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"services":                 "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"services":                 "api.v1, web",
+			},
+			expQuery: `
+(
+	(
+		sum(
+			rate(http_request_duration_seconds_count{ service=~"api\\.v1|web", status_code=~"(5..|429|431)"}[{{ .window }}])
+		)
+		/
+		(sum(
+			rate(http_request_duration_seconds_count{ service=~"api\\.v1|web"}[{{ .window }}])
+		) > 0)
+	) AND on() sum(rate(http_request_duration_seconds_count{ service=~"api\\.v1|web"}[{{ .window }}])) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"A non numeric minimum requests per second, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "ten",
+			},
+			expErr: true,
+		},
+
+		"An invalid traffic guard group by label, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"minimumRequestsPerSecond": "10",
+				"trafficGuardGroupBy":      "service,",
+			},
+			expErr: true,
+		},
+
+		"Traffic guard group by provided should evaluate the guard per group.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "api-.*",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"additionalLabels":         "route=~\"/v1/.*\"",
+				"minimumRequestsPerSecond": "10",
+				"trafficGuardGroupBy":      "service",
+			},
+			expQuery: `
+(
+	(
+		sum(
+			sum by (service) (
+				rate(http_request_duration_seconds_count{ route=~"/v1/.*", service=~"api-.*", status_code=~"(5..|429|431)"}[{{ .window }}])
+			) AND on(service) sum by (service) (
+				rate(http_request_duration_seconds_count{ route=~"/v1/.*", service=~"api-.*"}[{{ .window }}])
+			) > 10
+		)
+		/
+		(sum(
+			sum by (service) (
+				rate(http_request_duration_seconds_count{ route=~"/v1/.*", service=~"api-.*"}[{{ .window }}])
+			) > 10
+		) > 0)
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"Both an error label value and error weights, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorLabelValue":          "(5..|429|431)",
+				"errorWeights":             "5..:1, 429:0.25",
+				"minimumRequestsPerSecond": "10",
+			},
+			expErr: true,
+		},
+
+		"An invalid error weight, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorWeights":             "5..:heavy",
+				"minimumRequestsPerSecond": "10",
+			},
+			expErr: true,
+		},
+
+		"Overlapping error weights should count an error with the first matcher it matches.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorWeights":             "503:0.5, 5..:1, 4..:0.25",
+				"minimumRequestsPerSecond": "10",
+			},
+			expQuery: `
+(
+	(
+		(
+			0.5 * (sum(
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"503"}[{{ .window }}])
+			) OR on() vector(0))
+			+
+			1 * (sum(
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"5..", status_code!~"503"}[{{ .window }}])
+			) OR on() vector(0))
+			+
+			0.25 * (sum(
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"4..", status_code!~"503|5.."}[{{ .window }}])
+			) OR on() vector(0))
+		)
+		/
+		(sum(
+			rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+		) > 0)
+	) AND on() sum(rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"Error weights provided should return a weighted error ratio.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorWeights":             "5..:1, 429:0.25",
+				"minimumRequestsPerSecond": "10",
+			},
+			expQuery: `
+(
+	(
+		(
+			1 * (sum(
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"5.."}[{{ .window }}])
+			) OR on() vector(0))
+			+
+			0.25 * (sum(
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"429", status_code!~"5.."}[{{ .window }}])
+			) OR on() vector(0))
+		)
+		/
+		(sum(
+			rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+		) > 0)
+	) AND on() sum(rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])) > 10
+) OR on() vector(0)
+`,
+		},
+
+		"Error weights and traffic guard group by provided should return a weighted error ratio per group.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_count",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"errorLabelName":           "status_code",
+				"errorWeights":             "5..:1, 429:0.25",
+				"minimumRequestsPerSecond": "10",
+				"trafficGuardGroupBy":      "service",
+			},
+			expQuery: `
+(
+	(
+		sum(
+			(
+				1 * (sum by (service) (
+					rate(http_request_duration_seconds_count{ service=~"test", status_code=~"5.."}[{{ .window }}])
+				) OR sum by (service) (
+					rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+				) * 0)
+				+
+				0.25 * (sum by (service) (
+					rate(http_request_duration_seconds_count{ service=~"test", status_code=~"429", status_code!~"5.."}[{{ .window }}])
+				) OR sum by (service) (
+					rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+				) * 0)
+			) AND on(service) sum by (service) (
+				rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+			) > 10
+		)
+		/
+		(sum(
+			sum by (service) (
+				rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+			) > 10
+		) > 0)
+	)
 ) OR on() vector(0)
 `,
 		},
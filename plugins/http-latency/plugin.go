@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -17,39 +18,72 @@ const (
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 	1 - ((
 		(
-			sum(
-				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", le="{{ .upperLimitBucket }}" }[{{"{{ .window }}"}}])
-			)
+			{{ .stepSum }}sum(
+				rate({{ .metricNameBucket }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", le="{{ .upperLimitBucket }}" }[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
 			/
-			(sum(
-				rate({{ .metricNameCount }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}" }[{{"{{ .window }}"}}])
-			) > 0)
-		) AND on({{ .serviceLabelName }}) sum(rate({{ .metricNameCount }}{ {{ .serviceLabelName }}=~"{{ .serviceLabelValue }}" }[{{"{{ .window }}"}}])) > {{ .minimumRequestsPerSecond }}
+			({{ .stepSum }}sum(
+				rate({{ .metricNameTotal }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}" }[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }} > 0)
+		) AND on({{ .serviceLabelName }}) {{ .stepAvg }}sum(rate({{ .metricNameTotal }}{ {{ .serviceLabelName }}=~"{{ .serviceLabelValue }}" }[{{ .rateWindow }}]{{ .offset }})){{ .stepEnd }} > {{ .minimumRequestsPerSecond }}
 ) OR on() vector(1))
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 service metrics.
+// SLIPlugin will return a query that will return the latency error based on HTTP request duration histograms.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
 	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	serviceLabelName, err := getServiceLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	serviceLabelValue, err := getServiceLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	upperLimitBucket, err := getUpperLimitBucket(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	minimumRequestsPerSecond, err := getMinimumRequestsPerSecond(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
 
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	totalMetricName, err := getTotalMetricName(options)
 	if err != nil {
 		return "", fmt.Errorf("Error parsing options: %w", err)
 	}
 
 	var b bytes.Buffer
 	data := map[string]string{
-		"metricName":               metricName,
-		"metricNameCount":          strings.Replace(metricName, "_bucket", "_count", 1),
+		"metricNameBucket":         metricName + "_bucket",
+		"metricNameTotal":          totalMetricName,
 		"serviceLabelName":         serviceLabelName,
 		"serviceLabelValue":        serviceLabelValue,
 		"upperLimitBucket":         upperLimitBucket,
 		"additionalLabels":         getAdditionalLabels(options),
 		"minimumRequestsPerSecond": minimumRequestsPerSecond,
 	}
+	for k, v := range steps {
+		data[k] = v
+	}
 	err = queryTpl.Execute(&b, data)
 	if err != nil {
 		return "", fmt.Errorf("could not render query template: %w", err)
@@ -84,6 +118,17 @@ func getServiceLabelValue(options map[string]string) (string, error) {
 	value := options["serviceLabelValue"]
 	value = strings.TrimSpace(value)
 
+	values := options["services"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'serviceLabelValue' and 'services' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("services", values)
+	}
+
 	if value == "" {
 		return "", fmt.Errorf("'serviceLabelValue' is required")
 	}
@@ -113,7 +158,41 @@ func getMetricName(options map[string]string) (string, error) {
 		return "", fmt.Errorf("'metricName' is required")
 	}
 
-	return metricName, nil
+	return getHistogramBaseName(metricName), nil
+}
+
+func getTotalMetricName(options map[string]string) (string, error) {
+	totalMetricName := options["totalMetricName"]
+	totalMetricName = strings.TrimSpace(totalMetricName)
+
+	if totalMetricName == "" {
+		metricName, err := getMetricName(options)
+		if err != nil {
+			return "", err
+		}
+
+		return metricName + "_count", nil
+	}
+
+	if !metricNameRegexp.MatchString(totalMetricName) {
+		return "", fmt.Errorf("invalid metric name for 'totalMetricName': %q", totalMetricName)
+	}
+
+	return totalMetricName, nil
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// getHistogramBaseName strips the series suffix from a histogram metric name, so the base name or the name of any
+// of its series can be used.
+func getHistogramBaseName(metricName string) string {
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if strings.HasSuffix(metricName, suffix) {
+			return strings.TrimSuffix(metricName, suffix)
+		}
+	}
+
+	return metricName
 }
 
 func getMinimumRequestsPerSecond(options map[string]string) (string, error) {
@@ -124,3 +203,107 @@ func getMinimumRequestsPerSecond(options map[string]string) (string, error) {
 
 	return minimumRequestsPerSecond, nil
 }
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// Rates compared to the traffic guard are averaged over the steps instead of summed. The offset moves to the steps to
+// shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepAvg":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenanceRateWindow"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenanceRateWindow': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepAvg":    "avg_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
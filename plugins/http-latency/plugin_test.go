@@ -68,6 +68,171 @@ func TestSLIPlugin(t *testing.T) {
 			) > 0)
 		) AND on(service) sum(rate(nginx_ingress_controller_request_duration_seconds_count{ service=~"test" }[{{ .window }}])) > 10
 ) OR on() vector(1))
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"maintenanceSeries":        `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+	1 - ((
+		(
+			sum_over_time((sum(
+				rate(http_request_duration_seconds_bucket{ service=~"test", le="0.5" }[5m])
+			) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+			/
+			(sum_over_time((sum(
+				rate(http_request_duration_seconds_count{ service=~"test" }[5m])
+			) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+		) AND on(service) avg_over_time((sum(rate(http_request_duration_seconds_count{ service=~"test" }[5m])) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 10
+) OR on() vector(1))
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"offset":                   "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"offset":                   "5m",
+			},
+			expQuery: `
+	1 - ((
+		(
+			sum(
+				rate(http_request_duration_seconds_bucket{ service=~"test", le="0.5" }[{{ .window }}] offset 5m)
+			)
+			/
+			(sum(
+				rate(http_request_duration_seconds_count{ service=~"test" }[{{ .window }}] offset 5m)
+			) > 0)
+		) AND on(service) sum(rate(http_request_duration_seconds_count{ service=~"test" }[{{ .window }}] offset 5m)) > 10
+) OR on() vector(1))
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"serviceLabelValue":        "test",
+				"services":                 "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"services":                 "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"services":                 "api.v1, web",
+			},
+			expQuery: `
+	1 - ((
+		(
+			sum(
+				rate(http_request_duration_seconds_bucket{ service=~"api\\.v1|web", le="0.5" }[{{ .window }}])
+			)
+			/
+			(sum(
+				rate(http_request_duration_seconds_count{ service=~"api\\.v1|web" }[{{ .window }}])
+			) > 0)
+		) AND on(service) sum(rate(http_request_duration_seconds_count{ service=~"api\\.v1|web" }[{{ .window }}])) > 10
+) OR on() vector(1))
+`,
+		},
+
+		"A histogram base name should be accepted as metric name.": {
+			options: map[string]string{
+				"metricName":               "storage_bucket_request_duration_seconds",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+			},
+			expQuery: `
+	1 - ((
+		(
+			sum(
+				rate(storage_bucket_request_duration_seconds_bucket{ service=~"test", le="0.5" }[{{ .window }}])
+			)
+			/
+			(sum(
+				rate(storage_bucket_request_duration_seconds_count{ service=~"test" }[{{ .window }}])
+			) > 0)
+		) AND on(service) sum(rate(storage_bucket_request_duration_seconds_count{ service=~"test" }[{{ .window }}])) > 10
+) OR on() vector(1))
+`,
+		},
+
+		"An invalid total metric name, should fail.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"totalMetricName":          "http-requests-total",
+			},
+			expErr: true,
+		},
+
+		"Total metric name provided should be used as denominator and traffic guard.": {
+			options: map[string]string{
+				"metricName":               "http_request_duration_seconds_bucket",
+				"serviceLabelName":         "service",
+				"serviceLabelValue":        "test",
+				"upperLimitBucket":         "0.5",
+				"minimumRequestsPerSecond": "10",
+				"totalMetricName":          "http_requests_total",
+			},
+			expQuery: `
+	1 - ((
+		(
+			sum(
+				rate(http_request_duration_seconds_bucket{ service=~"test", le="0.5" }[{{ .window }}])
+			)
+			/
+			(sum(
+				rate(http_requests_total{ service=~"test" }[{{ .window }}])
+			) > 0)
+		) AND on(service) sum(rate(http_requests_total{ service=~"test" }[{{ .window }}])) > 10
+) OR on() vector(1))
 `,
 		},
 	}
@@ -0,0 +1,406 @@
+package tenanterrorrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/http-tenant-error-rate"
+)
+
+const (
+	aggregationWorst          = "worst"
+	aggregationBreachFraction = "breachFraction"
+)
+
+var worstQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+max(
+	(
+		{{ .stepSum }}sum by ({{ .tenantLabelName }}) (
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", {{ .errorLabelName }}=~"{{ .errorLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+		/
+		({{ .stepSum }}sum by ({{ .tenantLabelName }}) (
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+{{- if .stepAvg }} AND on({{ .tenantLabelName }}) {{ .stepAvg }}sum by ({{ .tenantLabelName }}) (
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+{{- end }} > {{ .minimumTenantRequestsPerSecond }})
+	)
+) OR on() vector(0)
+`))
+
+var breachFractionQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	count(
+		(
+			{{ .stepSum }}sum by ({{ .tenantLabelName }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}", {{ .errorLabelName }}=~"{{ .errorLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
+			/
+			({{ .stepSum }}sum by ({{ .tenantLabelName }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
+{{- if .stepAvg }} AND on({{ .tenantLabelName }}) {{ .stepAvg }}sum by ({{ .tenantLabelName }}) (
+				rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+			){{ .stepEnd }}
+{{- end }} > {{ .minimumTenantRequestsPerSecond }})
+		) > {{ .errorRateThreshold }}
+	)
+	/
+	count(
+		{{ .stepSum }}sum by ({{ .tenantLabelName }}) (
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+{{- if .stepAvg }} AND on({{ .tenantLabelName }}) {{ .stepAvg }}sum by ({{ .tenantLabelName }}) (
+			rate({{ .metricName }}{ {{ .additionalLabels }}{{ .serviceLabelName }}=~"{{ .serviceLabelValue }}"}[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+{{- end }} > {{ .minimumTenantRequestsPerSecond }}
+	)
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the error ratio of the worst tenant, or the fraction of tenants
+// whose error ratio is above a threshold, based on the same metrics as the http-error-rate plugin.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	serviceLabelName, err := getServiceLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	serviceLabelValue, err := getServiceLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	errorLabelName, err := getErrorLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	errorLabelValue, err := getErrorLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	tenantLabelName, err := getTenantLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	minimumTenantRequestsPerSecond, err := getMinimumTenantRequestsPerSecond(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	aggregation, err := getAggregation(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	data := map[string]string{
+		"metricName":                     metricName,
+		"serviceLabelName":               serviceLabelName,
+		"serviceLabelValue":              serviceLabelValue,
+		"errorLabelName":                 errorLabelName,
+		"errorLabelValue":                errorLabelValue,
+		"tenantLabelName":                tenantLabelName,
+		"additionalLabels":               getAdditionalLabels(options),
+		"minimumTenantRequestsPerSecond": minimumTenantRequestsPerSecond,
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+
+	queryTpl := worstQueryTpl
+	if aggregation == aggregationBreachFraction {
+		errorRateThreshold, err := getErrorRateThreshold(options)
+		if err != nil {
+			return "", fmt.Errorf("Error parsing options: %w", err)
+		}
+
+		data["errorRateThreshold"] = errorRateThreshold
+		queryTpl = breachFractionQueryTpl
+	}
+
+	var b bytes.Buffer
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getServiceLabelName(options map[string]string) (string, error) {
+	label := options["serviceLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'serviceLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+func getServiceLabelValue(options map[string]string) (string, error) {
+	value := options["serviceLabelValue"]
+	value = strings.TrimSpace(value)
+
+	values := options["services"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'serviceLabelValue' and 'services' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("services", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'serviceLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'serviceLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+func getErrorLabelName(options map[string]string) (string, error) {
+	label := options["errorLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'errorLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+func getErrorLabelValue(options map[string]string) (string, error) {
+	value := options["errorLabelValue"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return "", fmt.Errorf("'errorLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'errorLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+func getTenantLabelName(options map[string]string) (string, error) {
+	label := options["tenantLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'tenantLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+func getMetricName(options map[string]string) (string, error) {
+	metricName := options["metricName"]
+	if metricName == "" {
+		return "", fmt.Errorf("'metricName' is required")
+	}
+
+	return metricName, nil
+}
+
+func getMinimumTenantRequestsPerSecond(options map[string]string) (string, error) {
+	minimumTenantRequestsPerSecond := options["minimumTenantRequestsPerSecond"]
+	minimumTenantRequestsPerSecond = strings.TrimSpace(minimumTenantRequestsPerSecond)
+
+	if minimumTenantRequestsPerSecond == "" {
+		return "", fmt.Errorf("'minimumTenantRequestsPerSecond' is required")
+	}
+
+	_, err := strconv.ParseFloat(minimumTenantRequestsPerSecond, 64)
+	if err != nil {
+		return "", fmt.Errorf("'minimumTenantRequestsPerSecond' is not a valid number: %w", err)
+	}
+
+	return minimumTenantRequestsPerSecond, nil
+}
+
+func getAggregation(options map[string]string) (string, error) {
+	aggregation := options["aggregation"]
+	aggregation = strings.TrimSpace(aggregation)
+
+	switch aggregation {
+	case "":
+		return aggregationWorst, nil
+	case aggregationWorst, aggregationBreachFraction:
+		return aggregation, nil
+	}
+
+	return "", fmt.Errorf("invalid 'aggregation' %q, must be %q or %q", aggregation, aggregationWorst, aggregationBreachFraction)
+}
+
+func getErrorRateThreshold(options map[string]string) (string, error) {
+	threshold := options["errorRateThreshold"]
+	threshold = strings.TrimSpace(threshold)
+
+	if threshold == "" {
+		return "", fmt.Errorf("'errorRateThreshold' is required when 'aggregation' is %q", aggregationBreachFraction)
+	}
+
+	value, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return "", fmt.Errorf("'errorRateThreshold' is not a valid number: %w", err)
+	}
+
+	if value < 0 || value > 1 {
+		return "", fmt.Errorf("'errorRateThreshold' must be between 0 and 1")
+	}
+
+	return threshold, nil
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// Rates compared to the traffic guard are averaged over the steps instead of summed. The offset moves to the steps to
+// shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepAvg":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenanceRateWindow"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenanceRateWindow': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepAvg":    "avg_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
@@ -0,0 +1,196 @@
+package tenanterrorrate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	tenanterrorrate "github.com/lokalise/common-sloth-sli-plugins/plugins/http-tenant-error-rate"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	validOptions := func(extra map[string]string) map[string]string {
+		options := map[string]string{
+			"metricName":                     "http_request_duration_seconds_count",
+			"serviceLabelName":               "service",
+			"serviceLabelValue":              "test",
+			"errorLabelName":                 "status_code",
+			"errorLabelValue":                "(5..|429)",
+			"tenantLabelName":                "team_id",
+			"minimumTenantRequestsPerSecond": "0.1",
+		}
+		for k, v := range extra {
+			options[k] = v
+		}
+
+		return options
+	}
+
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Empty tenant label name, should fail.": {
+			options: validOptions(map[string]string{"tenantLabelName": ""}),
+			expErr:  true,
+		},
+
+		"An invalid service label value, should fail.": {
+			options: validOptions(map[string]string{"serviceLabelValue": "([xyz"}),
+			expErr:  true,
+		},
+
+		"A non numeric minimum tenant requests per second, should fail.": {
+			options: validOptions(map[string]string{"minimumTenantRequestsPerSecond": "ten"}),
+			expErr:  true,
+		},
+
+		"An unknown aggregation, should fail.": {
+			options: validOptions(map[string]string{"aggregation": "avg"}),
+			expErr:  true,
+		},
+
+		"Breach fraction aggregation without a threshold, should fail.": {
+			options: validOptions(map[string]string{"aggregation": "breachFraction"}),
+			expErr:  true,
+		},
+
+		"Breach fraction aggregation with an out of range threshold, should fail.": {
+			options: validOptions(map[string]string{"aggregation": "breachFraction", "errorRateThreshold": "5"}),
+			expErr:  true,
+		},
+
+		"Without aggregation, it should return the worst tenant query.": {
+			options: validOptions(map[string]string{"additionalLabels": "route=~\".*\""}),
+			expQuery: `
+max(
+	(
+		sum by (team_id) (
+			rate(http_request_duration_seconds_count{ route=~".*", service=~"test", status_code=~"(5..|429)"}[{{ .window }}])
+		)
+		/
+		(sum by (team_id) (
+			rate(http_request_duration_seconds_count{ route=~".*", service=~"test"}[{{ .window }}])
+		) > 0.1)
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"With breach fraction aggregation, it should return the fraction of tenants in breach.": {
+			options: validOptions(map[string]string{"aggregation": "breachFraction", "errorRateThreshold": "0.05"}),
+			expQuery: `
+(
+	count(
+		(
+			sum by (team_id) (
+				rate(http_request_duration_seconds_count{ service=~"test", status_code=~"(5..|429)"}[{{ .window }}])
+			)
+			/
+			(sum by (team_id) (
+				rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+			) > 0.1)
+		) > 0.05
+	)
+	/
+	count(
+		sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}])
+		) > 0.1
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: validOptions(map[string]string{"maintenanceSeries": `maintenance_active{service="test"} == 1`}),
+			expQuery: `
+max(
+	(
+		sum_over_time((sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test", status_code=~"(5..|429)"}[5m])
+		) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+		/
+		(sum_over_time((sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test"}[5m])
+		) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) AND on(team_id) avg_over_time((sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test"}[5m])
+		) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0.1)
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: validOptions(map[string]string{"offset": "5 minutes"}),
+			expErr:  true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: validOptions(map[string]string{"offset": "5m"}),
+			expQuery: `
+max(
+	(
+		sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test", status_code=~"(5..|429)"}[{{ .window }}] offset 5m)
+		)
+		/
+		(sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"test"}[{{ .window }}] offset 5m)
+		) > 0.1)
+	)
+) OR on() vector(0)
+`,
+		},
+
+		"Both a service label value and a service list, should fail.": {
+			options: validOptions(map[string]string{"services": "api,web"}),
+			expErr:  true,
+		},
+
+		"A service list with an empty value, should fail.": {
+			options: validOptions(map[string]string{"serviceLabelValue": "", "services": "api,,web"}),
+			expErr:  true,
+		},
+
+		"A service list should be escaped and joined into an exact alternation.": {
+			options: validOptions(map[string]string{"serviceLabelValue": "", "services": "api.v1, web"}),
+			expQuery: `
+max(
+	(
+		sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"api\\.v1|web", status_code=~"(5..|429)"}[{{ .window }}])
+		)
+		/
+		(sum by (team_id) (
+			rate(http_request_duration_seconds_count{ service=~"api\\.v1|web"}[{{ .window }}])
+		) > 0.1)
+	)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := tenanterrorrate.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,445 @@
+package apdex
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/http/apdex"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+1 - (
+	(
+		{{ .stepSum }}sum(
+			rate({{ .bucket_metric_name }}{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}", le="{{ .satisfied_bucket }}" }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+		+
+		{{ .stepSum }}sum(
+			rate({{ .bucket_metric_name }}{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}", le="{{ .tolerating_bucket }}" }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }}
+	)
+	/
+	(2 * {{ .stepSum }}sum(
+		rate({{ .total_metric_name }}{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}"}[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
+) OR on() vector(0)
+`))
+
+// labelConvention holds the metric and label names used by an instrumentation convention, and the default buckets
+// of its histogram in seconds.
+type labelConvention struct {
+	metricName   string
+	service      string
+	route        string
+	milliseconds bool
+	buckets      string
+}
+
+var labelConventions = map[string]labelConvention{
+	"default": {
+		metricName: "http_request_duration_seconds",
+		service:    "service",
+		route:      "route",
+		buckets:    "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10",
+	},
+	// OpenTelemetry HTTP semantic conventions 1.x.
+	"otel": {
+		metricName: "http_server_request_duration_seconds",
+		service:    "service_name",
+		route:      "http_route",
+		buckets:    "0.005,0.01,0.025,0.05,0.075,0.1,0.25,0.5,0.75,1,2.5,5,7.5,10",
+	},
+	// OpenTelemetry HTTP semantic conventions before 1.0, the duration is in milliseconds.
+	"otel-legacy": {
+		metricName:   "http_server_duration_milliseconds",
+		service:      "service_name",
+		route:        "http_route",
+		milliseconds: true,
+		buckets:      "0,0.005,0.01,0.025,0.05,0.075,0.1,0.25,0.5,0.75,1,2.5,5,7.5,10",
+	},
+}
+
+// SLIPlugin will return a query that will return the Apdex error based on HTTP request duration histograms. Requests
+// faster than T are satisfied, requests faster than T times the tolerating multiplier are tolerating and count half,
+// so the error is 1 - (satisfied + tolerating / 2) / total.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	convention, err := getLabelConvention(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get label convention: %w", err)
+	}
+
+	service, err := getServiceName(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get service name: %w", err)
+	}
+
+	satisfiedBucket, toleratingBucket, err := getBuckets(options, convention)
+	if err != nil {
+		return "", fmt.Errorf("could not get buckets: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
+	totalMetricName, err := getTotalMetricName(options, convention)
+	if err != nil {
+		return "", fmt.Errorf("could not get total metric name: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"bucket_metric_name": getMetricName(options, convention) + "_bucket",
+		"service_label":      convention.service,
+		"route_label":        convention.route,
+		"total_metric_name":  totalMetricName,
+		"filter":             getFilter(options),
+		"serviceName":        service,
+		"satisfied_bucket":   satisfiedBucket,
+		"tolerating_bucket":  toleratingBucket,
+		"route":              getRoute(options),
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getFilter(options map[string]string) string {
+	filter := options["filter"]
+	filter = strings.Trim(filter, "{},")
+	if filter != "" {
+		filter += ","
+	}
+
+	return filter
+}
+
+func getServiceName(options map[string]string) (string, error) {
+	service := options["service_name_regex"]
+	service = strings.TrimSpace(service)
+
+	services := options["services"]
+	services = strings.TrimSpace(services)
+
+	if service != "" && services != "" {
+		return "", fmt.Errorf("only one of service_name_regex and services can be set")
+	}
+
+	if services != "" {
+		return getExactMatchRegex("services", services)
+	}
+
+	if service == "" {
+		return "", fmt.Errorf("service name is required")
+	}
+
+	_, err := regexp.Compile(service)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return service, nil
+}
+
+func getRoute(options map[string]string) string {
+	route := options["route_regex"]
+	route = strings.TrimSpace(route)
+
+	if route == "" {
+		route = ".*"
+	}
+
+	return route
+}
+
+// getBuckets returns the satisfied bucket T and the tolerating bucket T times the tolerating multiplier. Both have
+// to be buckets of the histogram, given in seconds with the "buckets" option or the default buckets of the label
+// convention. They are converted to milliseconds when the histogram of the label convention is in milliseconds.
+func getBuckets(options map[string]string, convention labelConvention) (string, string, error) {
+	bucket := options["bucket"]
+	if bucket == "" {
+		return "", "", fmt.Errorf(`"bucket" option is required`)
+	}
+
+	satisfied, err := strconv.ParseFloat(bucket, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("not a valid bucket, can't parse to float64: %w", err)
+	}
+
+	multiplier, err := getToleratingMultiplier(options)
+	if err != nil {
+		return "", "", err
+	}
+
+	buckets, err := getHistogramBuckets(options, convention)
+	if err != nil {
+		return "", "", err
+	}
+
+	satisfiedBucket, ok := findBucket(buckets, satisfied)
+	if !ok {
+		return "", "", fmt.Errorf("bucket %q is not a bucket of the histogram", bucket)
+	}
+
+	tolerating := math.Round(satisfied*multiplier*1e9) / 1e9
+	toleratingBucket, ok := findBucket(buckets, tolerating)
+	if !ok {
+		return "", "", fmt.Errorf("tolerating bucket %s is not a bucket of the histogram", strconv.FormatFloat(tolerating, 'f', -1, 64))
+	}
+
+	if convention.milliseconds {
+		return toMilliseconds(satisfied), toMilliseconds(tolerating), nil
+	}
+
+	return satisfiedBucket, toleratingBucket, nil
+}
+
+func getToleratingMultiplier(options map[string]string) (float64, error) {
+	multiplier := options["tolerating_multiplier"]
+	multiplier = strings.TrimSpace(multiplier)
+
+	if multiplier == "" {
+		return 4, nil
+	}
+
+	value, err := strconv.ParseFloat(multiplier, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a valid tolerating multiplier, can't parse to float64: %w", err)
+	}
+
+	if value <= 1 {
+		return 0, fmt.Errorf("invalid tolerating multiplier %q, must be greater than 1", multiplier)
+	}
+
+	return value, nil
+}
+
+func getHistogramBuckets(options map[string]string, convention labelConvention) ([]string, error) {
+	buckets := options["buckets"]
+	buckets = strings.TrimSpace(buckets)
+
+	if buckets == "" {
+		buckets = convention.buckets
+	}
+
+	var values []string
+	for _, bucket := range strings.Split(buckets, ",") {
+		bucket = strings.TrimSpace(bucket)
+
+		_, err := strconv.ParseFloat(bucket, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid bucket in buckets, can't parse to float64: %w", err)
+		}
+
+		values = append(values, bucket)
+	}
+
+	return values, nil
+}
+
+// findBucket returns the bucket with the given value as it is written in the bucket list, which has to match the le
+// label of the histogram.
+func findBucket(buckets []string, value float64) (string, bool) {
+	for _, bucket := range buckets {
+		v, _ := strconv.ParseFloat(bucket, 64)
+		if math.Abs(v-value) < 1e-9 {
+			return bucket, true
+		}
+	}
+
+	return "", false
+}
+
+func toMilliseconds(seconds float64) string {
+	milliseconds := math.Round(seconds*1e6) / 1e3
+	return strconv.FormatFloat(milliseconds, 'f', -1, 64)
+}
+
+func getMetricName(options map[string]string, convention labelConvention) string {
+	metricName := options["metric_name"]
+	if metricName == "" {
+		metricName = convention.metricName
+	}
+
+	return getHistogramBaseName(metricName)
+}
+
+func getTotalMetricName(options map[string]string, convention labelConvention) (string, error) {
+	totalMetricName := options["total_metric_name"]
+	totalMetricName = strings.TrimSpace(totalMetricName)
+
+	if totalMetricName == "" {
+		return getMetricName(options, convention) + "_count", nil
+	}
+
+	if !metricNameRegexp.MatchString(totalMetricName) {
+		return "", fmt.Errorf("invalid metric name: %q", totalMetricName)
+	}
+
+	return totalMetricName, nil
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// getHistogramBaseName strips the series suffix from a histogram metric name, so the base name or the name of any
+// of its series can be used.
+func getHistogramBaseName(metricName string) string {
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if strings.HasSuffix(metricName, suffix) {
+			return strings.TrimSuffix(metricName, suffix)
+		}
+	}
+
+	return metricName
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
+
+func getLabelConvention(options map[string]string) (labelConvention, error) {
+	name := options["label_convention"]
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		name = "default"
+	}
+
+	convention, ok := labelConventions[name]
+	if !ok {
+		return labelConvention{}, fmt.Errorf("unknown label convention %q, must be default, otel or otel-legacy", name)
+	}
+
+	serviceLabel := options["service_label"]
+	serviceLabel = strings.TrimSpace(serviceLabel)
+
+	if serviceLabel != "" {
+		if !labelNameRegexp.MatchString(serviceLabel) {
+			return labelConvention{}, fmt.Errorf("invalid label name: %q", serviceLabel)
+		}
+
+		convention.service = serviceLabel
+	}
+
+	return convention, nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
@@ -0,0 +1,157 @@
+package apdex_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	apdex "github.com/lokalise/common-sloth-sli-plugins/plugins/http/apdex"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service, should fail.": {
+			options: map[string]string{"bucket": "0.25"},
+			expErr:  true,
+		},
+
+		"Without bucket, should fail.": {
+			options: map[string]string{"service_name_regex": "api"},
+			expErr:  true,
+		},
+
+		"A bucket that is not a bucket of the histogram, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "api",
+				"bucket":             "0.3",
+			},
+			expErr: true,
+		},
+
+		"A tolerating bucket that is not a bucket of the histogram, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "api",
+				"bucket":             "0.5",
+			},
+			expErr: true,
+		},
+
+		"A tolerating multiplier of at most 1, should fail.": {
+			options: map[string]string{
+				"service_name_regex":    "api",
+				"bucket":                "0.25",
+				"tolerating_multiplier": "1",
+			},
+			expErr: true,
+		},
+
+		"An invalid bucket list, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "api",
+				"bucket":             "0.25",
+				"buckets":            "0.25,1s",
+			},
+			expErr: true,
+		},
+
+		"Bucket provided should use four times the bucket as tolerating bucket.": {
+			options: map[string]string{
+				"service_name_regex": "api",
+				"bucket":             "0.25",
+			},
+			expQuery: `
+1 - (
+	(
+		sum(
+			rate(http_request_duration_seconds_bucket{ service=~"api", route=~".*", le="0.25" }[{{ .window }}])
+		)
+		+
+		sum(
+			rate(http_request_duration_seconds_bucket{ service=~"api", route=~".*", le="1" }[{{ .window }}])
+		)
+	)
+	/
+	(2 * sum(
+		rate(http_request_duration_seconds_count{ service=~"api", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Bucket list and tolerating multiplier provided should use the buckets as they are written.": {
+			options: map[string]string{
+				"services":              "api",
+				"bucket":                "0.5",
+				"tolerating_multiplier": "3",
+				"buckets":               "0.1, 0.5, 1.0, 1.5, 3.0",
+				"route_regex":           "/projects.*",
+				"filter":                `{env="live"}`,
+				"maintenance_series":    `maintenance_mode{app="api"}`,
+				"offset":                "5m",
+			},
+			expQuery: `
+1 - (
+	(
+		sum_over_time((sum(
+			rate(http_request_duration_seconds_bucket{ env="live",service=~"api", route=~"/projects.*", le="0.5" }[5m])
+		) unless on() (maintenance_mode{app="api"}))[{{ .window }}:1m] offset 5m)
+		+
+		sum_over_time((sum(
+			rate(http_request_duration_seconds_bucket{ env="live",service=~"api", route=~"/projects.*", le="1.5" }[5m])
+		) unless on() (maintenance_mode{app="api"}))[{{ .window }}:1m] offset 5m)
+	)
+	/
+	(2 * sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ env="live",service=~"api", route=~"/projects.*"}[5m])
+	) unless on() (maintenance_mode{app="api"}))[{{ .window }}:1m] offset 5m) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"OpenTelemetry legacy label convention provided should convert the buckets to milliseconds.": {
+			options: map[string]string{
+				"service_name_regex": "api",
+				"bucket":             "0.025",
+				"label_convention":   "otel-legacy",
+			},
+			expQuery: `
+1 - (
+	(
+		sum(
+			rate(http_server_duration_milliseconds_bucket{ service_name=~"api", http_route=~".*", le="25" }[{{ .window }}])
+		)
+		+
+		sum(
+			rate(http_server_duration_milliseconds_bucket{ service_name=~"api", http_route=~".*", le="100" }[{{ .window }}])
+		)
+	)
+	/
+	(2 * sum(
+		rate(http_server_duration_milliseconds_count{ service_name=~"api", http_route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := apdex.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -16,32 +17,119 @@ const (
 
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 (
-	sum(
-		rate({{ .metric_name }}_count{ {{ .filter }}service=~"{{ .serviceName }}", route=~"{{ .route }}", status_code=~"{{ .status }}" }[{{"{{ .window }}"}}])
+	{{ .stepSum }}sum(
+		rate({{ .metric_name }}_count{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}", {{ .status_label }}=~"{{ .status }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
+	/
+	({{ .stepSum }}sum(
+		rate({{ .metric_name }}_count{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}"}[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
+) OR on() vector(0)
+`))
+
+var weightedQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+{{- range $i, $w := .statusWeights }}{{ if $i }}
+		+{{ end }}
+		{{ $w.Weight }} * ({{ $.stepSum }}sum(
+			rate({{ $.metric_name }}_count{ {{ $.filter }}{{ $.service_label }}=~"{{ $.serviceName }}", {{ $.route_label }}=~"{{ $.route }}", {{ $.status_label }}=~"{{ $w.Matcher }}"{{ if $w.Exclude }}, {{ $.status_label }}!~"{{ $w.Exclude }}"{{ end }} }[{{ $.rateWindow }}]{{ $.offset }})
+		){{ $.stepEnd }} OR on() vector(0))
+{{- end }}
 	)
 	/
-	(sum(
-		rate({{ .metric_name }}_count{ {{ .filter }}service=~"{{ .serviceName }}", route=~"{{ .route }}"}[{{"{{ .window }}"}}])
-	) > 0)
+	({{ .stepSum }}sum(
+		rate({{ .metric_name }}_count{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}"}[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
 ) OR on() vector(0)
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 service metrics.
+type statusWeight struct {
+	Matcher string
+	Exclude string
+	Weight  string
+}
+
+// labelConvention holds the metric and label names used by an instrumentation convention.
+type labelConvention struct {
+	metricName string
+	service    string
+	route      string
+	status     string
+}
+
+var labelConventions = map[string]labelConvention{
+	"default": {
+		metricName: "http_request_duration_seconds",
+		service:    "service",
+		route:      "route",
+		status:     "status_code",
+	},
+	// OpenTelemetry HTTP semantic conventions 1.x.
+	"otel": {
+		metricName: "http_server_request_duration_seconds",
+		service:    "service_name",
+		route:      "http_route",
+		status:     "http_response_status_code",
+	},
+	// OpenTelemetry HTTP semantic conventions before 1.0, the duration is in milliseconds.
+	"otel-legacy": {
+		metricName: "http_server_duration_milliseconds",
+		service:    "service_name",
+		route:      "http_route",
+		status:     "http_status_code",
+	},
+}
+
+// SLIPlugin will return a query that will return the availability error based on HTTP request duration histograms.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	convention, err := getLabelConvention(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get label convention: %w", err)
+	}
+
 	service, err := getServiceName(options)
 	if err != nil {
 		return "", fmt.Errorf("could not get service name: %w", err)
 	}
 
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
+	statusWeights, err := getStatusWeights(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get status weights: %w", err)
+	}
+
 	var b bytes.Buffer
-	data := map[string]string{
-		"metric_name": getMetricName(options),
-		"filter":      getFilter(options),
-		"serviceName": service,
-		"status":      getStatus(options),
-		"route":       getRoute(options),
-	}
-	err = queryTpl.Execute(&b, data)
+	data := map[string]interface{}{
+		"metric_name":   getMetricName(options, convention),
+		"service_label": convention.service,
+		"route_label":   convention.route,
+		"status_label":  convention.status,
+		"filter":        getFilter(options),
+		"serviceName":   service,
+		"status":        getStatus(options),
+		"route":         getRoute(options),
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+
+	tpl := queryTpl
+	if len(statusWeights) > 0 {
+		data["statusWeights"] = statusWeights
+		tpl = weightedQueryTpl
+	}
+
+	err = tpl.Execute(&b, data)
 	if err != nil {
 		return "", fmt.Errorf("could not render query template: %w", err)
 	}
@@ -63,6 +151,17 @@ func getServiceName(options map[string]string) (string, error) {
 	service := options["service_name_regex"]
 	service = strings.TrimSpace(service)
 
+	services := options["services"]
+	services = strings.TrimSpace(services)
+
+	if service != "" && services != "" {
+		return "", fmt.Errorf("only one of service_name_regex and services can be set")
+	}
+
+	if services != "" {
+		return getExactMatchRegex("services", services)
+	}
+
 	if service == "" {
 		return "", fmt.Errorf("service name is required")
 	}
@@ -97,11 +196,192 @@ func getStatus(options map[string]string) string {
 	return status
 }
 
-func getMetricName(options map[string]string) string {
+// getStatusWeights parses a comma separated list of `matcher:weight` pairs. Every bad response is counted with the
+// weight of the first matcher it matches, the earlier matchers are excluded from the later ones so overlapping
+// matchers don't count a response twice.
+func getStatusWeights(options map[string]string) ([]statusWeight, error) {
+	weights := options["status_weights"]
+	weights = strings.TrimSpace(weights)
+
+	if weights == "" {
+		return nil, nil
+	}
+
+	if strings.TrimSpace(options["status_regex"]) != "" {
+		return nil, fmt.Errorf("only one of status_regex and status_weights can be set")
+	}
+
+	var statusWeights []statusWeight
+	var matchers []string
+	for _, pair := range strings.Split(weights, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid status weight %q, must be matcher:weight", pair)
+		}
+
+		matcher := strings.TrimSpace(pair[:i])
+		weight := strings.TrimSpace(pair[i+1:])
+
+		if matcher == "" {
+			return nil, fmt.Errorf("invalid status weight %q, matcher is required", pair)
+		}
+
+		_, err := regexp.Compile(matcher)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+
+		value, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a valid weight, can't parse to float64: %w", err)
+		}
+
+		if value <= 0 || value > 1 {
+			return nil, fmt.Errorf("invalid weight %q, must be greater than 0 and at most 1", weight)
+		}
+
+		statusWeights = append(statusWeights, statusWeight{Matcher: matcher, Exclude: strings.Join(matchers, "|"), Weight: weight})
+		matchers = append(matchers, matcher)
+	}
+
+	return statusWeights, nil
+}
+
+func getMetricName(options map[string]string, convention labelConvention) string {
 	metricName := options["metric_name"]
 	if metricName == "" {
-		metricName = "http_request_duration_seconds"
+		metricName = convention.metricName
 	}
 
 	return metricName
 }
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
+
+func getLabelConvention(options map[string]string) (labelConvention, error) {
+	name := options["label_convention"]
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		name = "default"
+	}
+
+	convention, ok := labelConventions[name]
+	if !ok {
+		return labelConvention{}, fmt.Errorf("unknown label convention %q, must be default, otel or otel-legacy", name)
+	}
+
+	serviceLabel := options["service_label"]
+	serviceLabel = strings.TrimSpace(serviceLabel)
+
+	if serviceLabel != "" {
+		if !labelNameRegexp.MatchString(serviceLabel) {
+			return labelConvention{}, fmt.Errorf("invalid label name: %q", serviceLabel)
+		}
+
+		convention.service = serviceLabel
+	}
+
+	return convention, nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
@@ -117,6 +117,240 @@ func TestSLIPlugin(t *testing.T) {
 		rate(http_request_duration_seconds_count{ k1="v2",k2="v2",service=~"test", route=~"/test.+"}[{{ .window }}])
 	) > 0)
 ) OR on() vector(0)
+`,
+		},
+
+		"A maintenance rate window shorter than the steps, should fail.": {
+			options: map[string]string{
+				"service_name_regex":      "test",
+				"maintenance_series":      `maintenance_active{service="test"} == 1`,
+				"maintenance_rate_window": "30s",
+			},
+			expErr: true,
+		},
+
+		"Maintenance rate window provided should be used for the rates of every step.": {
+			options: map[string]string{
+				"service_name_regex":      "test",
+				"maintenance_series":      `maintenance_active{service="test"} == 1`,
+				"maintenance_rate_window": "2m",
+			},
+			expQuery: `
+(
+	sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"(5..|429|431)" }[2m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+	/
+	(sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[2m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"maintenance_series": `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+(
+	sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"(5..|429|431)" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+	/
+	(sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"offset":             "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"offset":             "5m",
+			},
+			expQuery: `
+(
+	sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"(5..|429|431)" }[{{ .window }}] offset 5m)
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[{{ .window }}] offset 5m)
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"services":           "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"services": "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"services": "api.v1, web",
+			},
+			expQuery: `
+(
+	sum(
+		rate(http_request_duration_seconds_count{ service=~"api\\.v1|web", route=~".*", status_code=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"api\\.v1|web", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Both a status regex and status weights, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"status_regex":       "5..",
+				"status_weights":     "5..:1, 429:0.25",
+			},
+			expErr: true,
+		},
+
+		"A status weight without weight, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"status_weights":     "5..",
+			},
+			expErr: true,
+		},
+
+		"A status weight greater than one, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"status_weights":     "5..:2",
+			},
+			expErr: true,
+		},
+
+		"Overlapping status weights should count a response with the first matcher it matches.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"status_weights":     "503:0.5, 5..:1, 4..:0.25",
+			},
+			expQuery: `
+(
+	(
+		0.5 * (sum(
+			rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"503" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		1 * (sum(
+			rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"5..", status_code!~"503" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		0.25 * (sum(
+			rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"4..", status_code!~"503|5.." }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Status weights provided should return a weighted error ratio.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"status_weights":     "5..:1, 429:0.25",
+			},
+			expQuery: `
+(
+	(
+		1 * (sum(
+			rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"5.." }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		0.25 * (sum(
+			rate(http_request_duration_seconds_count{ service=~"test", route=~".*", status_code=~"429", status_code!~"5.." }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An unknown label convention, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"label_convention":   "otel-1.2",
+			},
+			expErr: true,
+		},
+
+		"An invalid service label, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"service_label":      "service.name",
+			},
+			expErr: true,
+		},
+
+		"OpenTelemetry label convention should use the semantic convention names.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"label_convention":   "otel",
+			},
+			expQuery: `
+(
+	sum(
+		rate(http_server_request_duration_seconds_count{ service_name=~"files-api", http_route=~".*", http_response_status_code=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_server_request_duration_seconds_count{ service_name=~"files-api", http_route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Legacy OpenTelemetry label convention and service label should use the pre 1.0 names.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"label_convention":   "otel-legacy",
+				"service_label":      "job",
+			},
+			expQuery: `
+(
+	sum(
+		rate(http_server_duration_milliseconds_count{ job=~"files-api", http_route=~".*", http_status_code=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_server_duration_milliseconds_count{ job=~"files-api", http_route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
 `,
 		},
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -17,35 +18,90 @@ const (
 
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 1 - (
-	sum(
-		rate({{ .metric_name }}_bucket{ {{ .filter }}service=~"{{ .serviceName }}", route=~"{{ .route }}", le="{{ .bucket }}" }[{{"{{ .window }}"}}])
-	)
+	{{ .stepSum }}sum(
+		rate({{ .bucket_metric_name }}{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}", le="{{ .bucket }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
 	/
-	(sum(
-		rate({{ .metric_name }}_count{ {{ .filter }}service=~"{{ .serviceName }}", route=~"{{ .route }}"}[{{"{{ .window }}"}}])
-	) > 0)
+	({{ .stepSum }}sum(
+		rate({{ .total_metric_name }}{ {{ .filter }}{{ .service_label }}=~"{{ .serviceName }}", {{ .route_label }}=~"{{ .route }}"}[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
 ) OR on() vector(0)
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 service metrics.
+// labelConvention holds the metric and label names used by an instrumentation convention.
+type labelConvention struct {
+	metricName   string
+	service      string
+	route        string
+	milliseconds bool
+}
+
+var labelConventions = map[string]labelConvention{
+	"default": {
+		metricName: "http_request_duration_seconds",
+		service:    "service",
+		route:      "route",
+	},
+	// OpenTelemetry HTTP semantic conventions 1.x.
+	"otel": {
+		metricName: "http_server_request_duration_seconds",
+		service:    "service_name",
+		route:      "http_route",
+	},
+	// OpenTelemetry HTTP semantic conventions before 1.0, the duration is in milliseconds.
+	"otel-legacy": {
+		metricName:   "http_server_duration_milliseconds",
+		service:      "service_name",
+		route:        "http_route",
+		milliseconds: true,
+	},
+}
+
+// SLIPlugin will return a query that will return the latency error based on HTTP request duration histograms.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	convention, err := getLabelConvention(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get label convention: %w", err)
+	}
+
 	service, err := getServiceName(options)
-	bucket, err := getBucket(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get service name: %w", err)
+	}
 
+	bucket, err := getBucket(options, convention)
 	if err != nil {
 		return "", fmt.Errorf(`could not get bucket: %w`, err)
 	}
+
+	offset, err := getOffset(options)
 	if err != nil {
-		return "", fmt.Errorf("could not get service name: %w", err)
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
+	totalMetricName, err := getTotalMetricName(options, convention)
+	if err != nil {
+		return "", fmt.Errorf("could not get total metric name: %w", err)
 	}
 
 	var b bytes.Buffer
 	data := map[string]string{
-		"metric_name": getMetricName(options),
-		"filter":      getFilter(options),
-		"serviceName": service,
-		"bucket":      bucket,
-		"route":       getRoute(options),
+		"bucket_metric_name": getMetricName(options, convention) + "_bucket",
+		"service_label":      convention.service,
+		"route_label":        convention.route,
+		"total_metric_name":  totalMetricName,
+		"filter":             getFilter(options),
+		"serviceName":        service,
+		"bucket":             bucket,
+		"route":              getRoute(options),
+	}
+	for k, v := range steps {
+		data[k] = v
 	}
 	err = queryTpl.Execute(&b, data)
 	if err != nil {
@@ -69,6 +125,17 @@ func getServiceName(options map[string]string) (string, error) {
 	service := options["service_name_regex"]
 	service = strings.TrimSpace(service)
 
+	services := options["services"]
+	services = strings.TrimSpace(services)
+
+	if service != "" && services != "" {
+		return "", fmt.Errorf("only one of service_name_regex and services can be set")
+	}
+
+	if services != "" {
+		return getExactMatchRegex("services", services)
+	}
+
 	if service == "" {
 		return "", fmt.Errorf("service name is required")
 	}
@@ -92,25 +159,191 @@ func getRoute(options map[string]string) string {
 	return route
 }
 
-func getBucket(options map[string]string) (string, error) {
+// getBucket takes the bucket in seconds and converts it to milliseconds when the histogram of the label convention
+// is in milliseconds.
+func getBucket(options map[string]string, convention labelConvention) (string, error) {
 	bucket := options["bucket"]
 	if bucket == "" {
 		return "", fmt.Errorf(`"bucket" option is required`)
 	}
 
-	_, err := strconv.ParseFloat(bucket, 64)
+	seconds, err := strconv.ParseFloat(bucket, 64)
 	if err != nil {
 		return "", fmt.Errorf("not a valid bucket, can't parse to float64: %w", err)
 	}
 
+	if convention.milliseconds {
+		milliseconds := math.Round(seconds*1e6) / 1e3
+		return strconv.FormatFloat(milliseconds, 'f', -1, 64), nil
+	}
+
 	return bucket, nil
 }
 
-func getMetricName(options map[string]string) string {
+func getMetricName(options map[string]string, convention labelConvention) string {
 	metricName := options["metric_name"]
 	if metricName == "" {
-		metricName = "http_request_duration_seconds"
+		metricName = convention.metricName
+	}
+
+	return getHistogramBaseName(metricName)
+}
+
+func getTotalMetricName(options map[string]string, convention labelConvention) (string, error) {
+	totalMetricName := options["total_metric_name"]
+	totalMetricName = strings.TrimSpace(totalMetricName)
+
+	if totalMetricName == "" {
+		return getMetricName(options, convention) + "_count", nil
+	}
+
+	if !metricNameRegexp.MatchString(totalMetricName) {
+		return "", fmt.Errorf("invalid metric name: %q", totalMetricName)
+	}
+
+	return totalMetricName, nil
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// getHistogramBaseName strips the series suffix from a histogram metric name, so the base name or the name of any
+// of its series can be used.
+func getHistogramBaseName(metricName string) string {
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if strings.HasSuffix(metricName, suffix) {
+			return strings.TrimSuffix(metricName, suffix)
+		}
 	}
 
 	return metricName
 }
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
+
+func getLabelConvention(options map[string]string) (labelConvention, error) {
+	name := options["label_convention"]
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		name = "default"
+	}
+
+	convention, ok := labelConventions[name]
+	if !ok {
+		return labelConvention{}, fmt.Errorf("unknown label convention %q, must be default, otel or otel-legacy", name)
+	}
+
+	serviceLabel := options["service_label"]
+	serviceLabel = strings.TrimSpace(serviceLabel)
+
+	if serviceLabel != "" {
+		if !labelNameRegexp.MatchString(serviceLabel) {
+			return labelConvention{}, fmt.Errorf("invalid label name: %q", serviceLabel)
+		}
+
+		convention.service = serviceLabel
+	}
+
+	return convention, nil
+}
+
+var labelNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
@@ -123,6 +123,183 @@ func TestSLIPlugin(t *testing.T) {
 		rate(http_request_duration_seconds_count{ k1="v2",k2="v2",service=~"test", route=~"/test.+"}[{{ .window }}])
 	) > 0)
 ) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"maintenance_series": `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+1 - (
+	sum_over_time((sum(
+		rate(http_request_duration_seconds_bucket{ service=~"test", route=~".*", le="0.5" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+	/
+	(sum_over_time((sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"offset":             "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"offset":             "5m",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(http_request_duration_seconds_bucket{ service=~"test", route=~".*", le="0.5" }[{{ .window }}] offset 5m)
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"test", route=~".*"}[{{ .window }}] offset 5m)
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"bucket":             "0.5",
+				"service_name_regex": "test",
+				"services":           "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"bucket":   "0.5",
+				"services": "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"bucket":   "0.5",
+				"services": "api.v1, web",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(http_request_duration_seconds_bucket{ service=~"api\\.v1|web", route=~".*", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_request_duration_seconds_count{ service=~"api\\.v1|web", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"A histogram series name should be accepted as metric name.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"metric_name":        "storage_bucket_request_duration_seconds_count",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(storage_bucket_request_duration_seconds_bucket{ service=~"test", route=~".*", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(storage_bucket_request_duration_seconds_count{ service=~"test", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid total metric name, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"total_metric_name":  "http-requests-total",
+			},
+			expErr: true,
+		},
+
+		"Total metric name provided should be used as denominator.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"total_metric_name":  "http_requests_total",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(http_request_duration_seconds_bucket{ service=~"test", route=~".*", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_requests_total{ service=~"test", route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An unknown label convention, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"label_convention":   "otel-1.2",
+			},
+			expErr: true,
+		},
+
+		"OpenTelemetry label convention should use the semantic convention names.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"bucket":             "0.5",
+				"label_convention":   "otel",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(http_server_request_duration_seconds_bucket{ service_name=~"files-api", http_route=~".*", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_server_request_duration_seconds_count{ service_name=~"files-api", http_route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Legacy OpenTelemetry label convention should convert the bucket to milliseconds.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"bucket":             "0.5",
+				"label_convention":   "otel-legacy",
+				"service_label":      "job",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(http_server_duration_milliseconds_bucket{ job=~"files-api", http_route=~".*", le="500" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(http_server_duration_milliseconds_count{ job=~"files-api", http_route=~".*"}[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
 `,
 		},
 	}
@@ -0,0 +1,137 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/istio/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without destination, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An invalid destination workload query, should fail.": {
+			options: map[string]string{"destination_workload_regex": "([xyz"},
+			expErr:  true,
+		},
+
+		"Both a destination workload regex and list, should fail.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"destination_workloads":      "files-api",
+			},
+			expErr: true,
+		},
+
+		"A destination service list with an empty value, should fail.": {
+			options: map[string]string{"destination_services": "files-api.files.svc.cluster.local,"},
+			expErr:  true,
+		},
+
+		"Destination lists should be escaped and joined into exact alternations.": {
+			options: map[string]string{
+				"destination_workloads": "files-api, files-worker",
+				"destination_services":  "files-api.files.svc.cluster.local",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			rate(istio_requests_total{ reporter="destination", destination_workload=~"files-api|files-worker", destination_service=~"files-api\\.files\\.svc\\.cluster\\.local", response_code=~"(5..|0)" }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		rate(istio_requests_total{ reporter="destination", destination_workload=~"files-api|files-worker", destination_service=~"files-api\\.files\\.svc\\.cluster\\.local" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An unknown reporter, should fail.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"reporter":                   "both",
+			},
+			expErr: true,
+		},
+
+		"An invalid response flag, should fail.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"response_flags":             "UF,.*",
+			},
+			expErr: true,
+		},
+
+		"With destination workload should return a valid query.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			rate(istio_requests_total{ reporter="destination", destination_workload=~"files-api", destination_service=~".*", response_code=~"(5..|0)" }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		rate(istio_requests_total{ reporter="destination", destination_workload=~"files-api", destination_service=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Response flags provided should also count flagged requests with any other response code.": {
+			options: map[string]string{
+				"reporter":                  "source",
+				"destination_service_regex": "files-api.files.svc.cluster.local",
+				"response_flags":            "UF, URX,UT",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			rate(istio_requests_total{ reporter="source", destination_workload=~".*", destination_service=~"files-api.files.svc.cluster.local", response_code=~"(5..|0)" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(sum(
+			rate(istio_requests_total{ reporter="source", destination_workload=~".*", destination_service=~"files-api.files.svc.cluster.local", response_code!~"(5..|0)", response_flags=~"(.*,)?(UF|URX|UT)(,.*)?" }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	(sum(
+		rate(istio_requests_total{ reporter="source", destination_workload=~".*", destination_service=~"files-api.files.svc.cluster.local" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
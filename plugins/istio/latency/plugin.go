@@ -0,0 +1,269 @@
+package latency
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/istio/latency"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+1 - (
+	{{ .stepSum }}sum(
+		rate(istio_request_duration_milliseconds_bucket{ {{ .filter }}reporter="{{ .reporter }}", destination_workload=~"{{ .workload }}", destination_service=~"{{ .service }}", le="{{ .bucket }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
+	/
+	({{ .stepSum }}sum(
+		rate(istio_request_duration_milliseconds_count{ {{ .filter }}reporter="{{ .reporter }}", destination_workload=~"{{ .workload }}", destination_service=~"{{ .service }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the latency error based on Istio standard request metrics.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	reporter, err := getReporter(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get reporter: %w", err)
+	}
+
+	workload, service, err := getDestination(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get destination: %w", err)
+	}
+
+	bucket, err := getBucket(options)
+	if err != nil {
+		return "", fmt.Errorf(`could not get bucket: %w`, err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"filter":   getFilter(options),
+		"reporter": reporter,
+		"workload": workload,
+		"service":  service,
+		"bucket":   bucket,
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getFilter(options map[string]string) string {
+	filter := options["filter"]
+	filter = strings.Trim(filter, "{},")
+	if filter != "" {
+		filter += ","
+	}
+
+	return filter
+}
+
+func getReporter(options map[string]string) (string, error) {
+	reporter := options["reporter"]
+	reporter = strings.TrimSpace(reporter)
+
+	switch reporter {
+	case "":
+		return "destination", nil
+	case "source", "destination":
+		return reporter, nil
+	}
+
+	return "", fmt.Errorf("invalid reporter %q, must be source or destination", reporter)
+}
+
+// getDestination returns the destination workload and service matchers, each of them can be given as a regex or as a
+// list of exact names. The one that isn't set matches any destination.
+func getDestination(options map[string]string) (string, string, error) {
+	workload, err := getDestinationValue(options, "destination_workload_regex", "destination_workloads")
+	if err != nil {
+		return "", "", err
+	}
+
+	service, err := getDestinationValue(options, "destination_service_regex", "destination_services")
+	if err != nil {
+		return "", "", err
+	}
+
+	if workload == "" && service == "" {
+		return "", "", fmt.Errorf("destination workload or destination service is required")
+	}
+
+	if workload == "" {
+		workload = ".*"
+	}
+
+	if service == "" {
+		service = ".*"
+	}
+
+	return workload, service, nil
+}
+
+func getDestinationValue(options map[string]string, regexKey, listKey string) (string, error) {
+	value := options[regexKey]
+	value = strings.TrimSpace(value)
+
+	values := options[listKey]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of %s and %s can be set", regexKey, listKey)
+	}
+
+	if values != "" {
+		return getExactMatchRegex(listKey, values)
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex: %w", err)
+	}
+
+	return value, nil
+}
+
+// getBucket takes the bucket in seconds, like the other latency plugins, and returns the matching le label of the
+// Istio histogram, which is in milliseconds.
+func getBucket(options map[string]string) (string, error) {
+	bucket := options["bucket"]
+	if bucket == "" {
+		return "", fmt.Errorf(`"bucket" option is required`)
+	}
+
+	seconds, err := strconv.ParseFloat(bucket, 64)
+	if err != nil {
+		return "", fmt.Errorf("not a valid bucket, can't parse to float64: %w", err)
+	}
+
+	milliseconds := math.Round(seconds*1e6) / 1e3
+
+	return strconv.FormatFloat(milliseconds, 'f', -1, 64), nil
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
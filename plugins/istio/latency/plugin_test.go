@@ -0,0 +1,120 @@
+package latency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	latency "github.com/lokalise/common-sloth-sli-plugins/plugins/istio/latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without destination, should fail.": {
+			options: map[string]string{"bucket": "0.3"},
+			expErr:  true,
+		},
+
+		"Both a destination workload regex and list, should fail.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"destination_workloads":      "files-api",
+			},
+			expErr: true,
+		},
+
+		"A destination service list with an empty value, should fail.": {
+			options: map[string]string{"destination_services": "files-api.files.svc.cluster.local,"},
+			expErr:  true,
+		},
+
+		"Destination lists should be escaped and joined into exact alternations.": {
+			options: map[string]string{
+				"destination_workloads": "files-api, files-worker",
+				"destination_services":  "files-api.files.svc.cluster.local",
+				"bucket":                "0.3",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(istio_request_duration_milliseconds_bucket{ reporter="destination", destination_workload=~"files-api|files-worker", destination_service=~"files-api\\.files\\.svc\\.cluster\\.local", le="300" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(istio_request_duration_milliseconds_count{ reporter="destination", destination_workload=~"files-api|files-worker", destination_service=~"files-api\\.files\\.svc\\.cluster\\.local" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Without bucket, should fail.": {
+			options: map[string]string{"destination_workload_regex": "files-api"},
+			expErr:  true,
+		},
+
+		"A non numeric bucket, should fail.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"bucket":                     "300ms",
+			},
+			expErr: true,
+		},
+
+		"Bucket in seconds should be converted to milliseconds.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"bucket":                     "0.3",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(istio_request_duration_milliseconds_bucket{ reporter="destination", destination_workload=~"files-api", destination_service=~".*", le="300" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(istio_request_duration_milliseconds_count{ reporter="destination", destination_workload=~"files-api", destination_service=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Bucket with a fractional number of seconds should be converted to milliseconds.": {
+			options: map[string]string{
+				"destination_workload_regex": "files-api",
+				"bucket":                     "2.5",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(istio_request_duration_milliseconds_bucket{ reporter="destination", destination_workload=~"files-api", destination_service=~".*", le="2500" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(istio_request_duration_milliseconds_count{ reporter="destination", destination_workload=~"files-api", destination_service=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := latency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
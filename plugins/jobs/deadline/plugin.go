@@ -0,0 +1,424 @@
+package deadline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/jobs/deadline"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+{{- range $i, $d := .deadlines }}{{ if $i }}
+		+{{ end }}
+		({{ $.stepSum }}sum(
+			increase({{ $.metricNameCount }}{ {{ $d.Selector }} }[{{ $.rateWindow }}]{{ $.offset }})
+		){{ $.stepEnd }} - {{ $.stepSum }}sum(
+			increase({{ $.metricNameBucket }}{ {{ $d.Selector }}, le="{{ $d.Deadline }}" }[{{ $.rateWindow }}]{{ $.offset }})
+		){{ $.stepEnd }} OR on() vector(0))
+{{- if $.inFlightMetricName }}
+		+
+		(count(
+			(timestamp({{ $.inFlightMetricName }}{ {{ $d.Selector }} }{{ $.inFlightOffset }}) - {{ $.inFlightMetricName }}{ {{ $d.Selector }} }{{ $.inFlightOffset }}) > {{ $d.Deadline }}{{ $.inFlightMaintenance }}
+		) OR on() vector(0))
+{{- end }}
+{{- end }}
+	)
+	/
+	((
+		({{ .stepSum }}sum(
+			increase({{ .metricNameCount }}{ {{ .selector }} }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} OR on() vector(0))
+{{- if .inFlightMetricName }}
+{{- range .deadlines }}
+		+
+		(count(
+			(timestamp({{ $.inFlightMetricName }}{ {{ .Selector }} }{{ $.inFlightOffset }}) - {{ $.inFlightMetricName }}{ {{ .Selector }} }{{ $.inFlightOffset }}) > {{ .Deadline }}{{ $.inFlightMaintenance }}
+		) OR on() vector(0))
+{{- end }}
+{{- end }}
+	) > 0)
+) OR on() vector(0)
+`))
+
+type queueDeadline struct {
+	Queue    string
+	Deadline string
+	Selector string
+}
+
+// SLIPlugin will return a query that will return the ratio of jobs that missed their deadline based on job duration
+// histograms. When an in-flight metric is given, the running jobs that are already past their deadline are counted
+// as bad as well. Such a job is counted again once it completes, when the window still contains its completion.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	queueLabelName, err := getQueueLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	jobNameSelector, err := getJobNameSelector(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	queueDeadlines, err := getQueueDeadlines(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	inFlightMetricName, err := getInFlightMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	additionalLabels := getAdditionalLabels(options)
+
+	// The in-flight jobs are a snapshot at the end of the window, so they are skipped when it falls into maintenance.
+	inFlightMaintenance := ""
+	if maintenance := strings.TrimSpace(options["maintenanceSeries"]); maintenance != "" && offset == "" {
+		inFlightMaintenance = fmt.Sprintf(" unless on() (%s)", maintenance)
+	} else if maintenance != "" {
+		inFlightMaintenance = fmt.Sprintf(" unless on() last_over_time((%s)[1m:1m]%s)", maintenance, offset)
+	}
+
+	var queues []string
+	for i, d := range queueDeadlines {
+		queues = append(queues, d.Queue)
+		queueDeadlines[i].Selector = fmt.Sprintf(`%s%s=~"%s"%s`, additionalLabels, queueLabelName, d.Queue, jobNameSelector)
+	}
+
+	var b bytes.Buffer
+	data := map[string]interface{}{
+		"metricNameCount":     metricName + "_count",
+		"metricNameBucket":    metricName + "_bucket",
+		"inFlightMetricName":  inFlightMetricName,
+		"inFlightOffset":      offset,
+		"inFlightMaintenance": inFlightMaintenance,
+		"selector":            fmt.Sprintf(`%s%s=~"%s"%s`, additionalLabels, queueLabelName, strings.Join(queues, "|"), jobNameSelector),
+		"deadlines":           queueDeadlines,
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getQueueLabelName(options map[string]string) (string, error) {
+	label := options["queueLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'queueLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+// getQueueDeadlines returns the queue matcher and deadline pairs. Either a single deadline applies to the queues
+// selected with 'queueLabelValue' or 'queues', or 'queueDeadlines' sets a deadline per queue as a comma separated
+// list of `queue:deadline` pairs.
+func getQueueDeadlines(options map[string]string) ([]queueDeadline, error) {
+	pairs := options["queueDeadlines"]
+	pairs = strings.TrimSpace(pairs)
+
+	if pairs == "" {
+		queue, err := getQueueLabelValue(options)
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, err := getDeadline("deadline", options["deadline"])
+		if err != nil {
+			return nil, err
+		}
+
+		return []queueDeadline{{Queue: queue, Deadline: deadline}}, nil
+	}
+
+	for _, key := range []string{"queueLabelValue", "queues", "deadline"} {
+		if strings.TrimSpace(options[key]) != "" {
+			return nil, fmt.Errorf("only one of '%s' and 'queueDeadlines' can be set", key)
+		}
+	}
+
+	var queueDeadlines []queueDeadline
+	for _, pair := range strings.Split(pairs, ",") {
+		i := strings.LastIndex(pair, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid queue deadline %q, must be queue:deadline", pair)
+		}
+
+		queue, err := getExactMatchRegex("queueDeadlines", pair[:i])
+		if err != nil {
+			return nil, err
+		}
+
+		deadline, err := getDeadline("queueDeadlines", pair[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		queueDeadlines = append(queueDeadlines, queueDeadline{Queue: queue, Deadline: deadline})
+	}
+
+	return queueDeadlines, nil
+}
+
+func getQueueLabelValue(options map[string]string) (string, error) {
+	value := options["queueLabelValue"]
+	value = strings.TrimSpace(value)
+
+	values := options["queues"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'queueLabelValue' and 'queues' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("queues", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'queueLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'queueLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+// getDeadline validates a deadline in seconds, it has to be one of the buckets of the histogram.
+func getDeadline(key, deadline string) (string, error) {
+	deadline = strings.TrimSpace(deadline)
+
+	if deadline == "" {
+		return "", fmt.Errorf("'%s' is required", key)
+	}
+
+	value, err := strconv.ParseFloat(deadline, 64)
+	if err != nil {
+		return "", fmt.Errorf("not a valid deadline for '%s', can't parse to float64: %w", key, err)
+	}
+
+	if value <= 0 {
+		return "", fmt.Errorf("invalid deadline for '%s' %q, must be greater than 0", key, deadline)
+	}
+
+	return deadline, nil
+}
+
+func getJobNameSelector(options map[string]string) (string, error) {
+	value := options["jobNameLabelValue"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return "", nil
+	}
+
+	label := options["jobNameLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'jobNameLabelName' name is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'jobNameLabelValue': %w", err)
+	}
+
+	return fmt.Sprintf(`, %s=~"%s"`, label, value), nil
+}
+
+func getMetricName(options map[string]string) (string, error) {
+	metricName := options["metricName"]
+	metricName = strings.TrimSpace(metricName)
+
+	if metricName == "" {
+		return "", fmt.Errorf("'metricName' is required")
+	}
+
+	if !metricNameRegexp.MatchString(metricName) {
+		return "", fmt.Errorf("invalid metric name for 'metricName': %q", metricName)
+	}
+
+	return getHistogramBaseName(metricName), nil
+}
+
+// getInFlightMetricName returns the optional gauge that holds the start timestamp of every running job.
+func getInFlightMetricName(options map[string]string) (string, error) {
+	metricName := options["inFlightMetricName"]
+	metricName = strings.TrimSpace(metricName)
+
+	if metricName != "" && !metricNameRegexp.MatchString(metricName) {
+		return "", fmt.Errorf("invalid metric name for 'inFlightMetricName': %q", metricName)
+	}
+
+	return metricName, nil
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// getHistogramBaseName strips the series suffix from a histogram metric name, so the base name or the name of any
+// of its series can be used.
+func getHistogramBaseName(metricName string) string {
+	for _, suffix := range []string{"_bucket", "_count", "_sum"} {
+		if strings.HasSuffix(metricName, suffix) {
+			return strings.TrimSuffix(metricName, suffix)
+		}
+	}
+
+	return metricName
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the increases
+// over the maintenance rate window, it must hold at least two scrapes for increase() to return anything, so it
+// defaults to 5m. The increases are divided by its minutes, so their sum over the steps is still a number of jobs that
+// can be added to the in-flight ones. The offset moves to the steps to shift the maintenance series together with the
+// metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenanceRateWindow"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenanceRateWindow': %q, must be at least 1m", rateWindow)
+	}
+
+	scale := ""
+	if seconds != 60 {
+		scale = " / " + strconv.FormatFloat(seconds/60, 'f', -1, 64)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)%s", maintenance, offset, scale),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
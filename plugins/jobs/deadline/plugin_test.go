@@ -0,0 +1,221 @@
+package deadline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	deadline "github.com/lokalise/common-sloth-sli-plugins/plugins/jobs/deadline"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without deadline, should fail.": {
+			options: map[string]string{
+				"metricName":      "bullmq_job_duration_seconds",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-export",
+			},
+			expErr: true,
+		},
+
+		"An invalid deadline, should fail.": {
+			options: map[string]string{
+				"metricName":      "bullmq_job_duration_seconds",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-export",
+				"deadline":        "2m",
+			},
+			expErr: true,
+		},
+
+		"Queue deadlines together with a deadline, should fail.": {
+			options: map[string]string{
+				"metricName":     "bullmq_job_duration_seconds",
+				"queueLabelName": "queue",
+				"queueDeadlines": "file-export:120",
+				"deadline":       "120",
+			},
+			expErr: true,
+		},
+
+		"A queue deadline without a deadline, should fail.": {
+			options: map[string]string{
+				"metricName":     "bullmq_job_duration_seconds",
+				"queueLabelName": "queue",
+				"queueDeadlines": "file-export",
+			},
+			expErr: true,
+		},
+
+		"An invalid in-flight metric name, should fail.": {
+			options: map[string]string{
+				"metricName":         "bullmq_job_duration_seconds",
+				"queueLabelName":     "queue",
+				"queueLabelValue":    "file-export",
+				"deadline":           "120",
+				"inFlightMetricName": "bullmq-job-started",
+			},
+			expErr: true,
+		},
+
+		"A deadline provided should return the ratio of jobs that took longer.": {
+			options: map[string]string{
+				"metricName":      "bullmq_job_duration_seconds_bucket",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-export",
+				"deadline":        "120",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export" }[{{ .window }}])
+		) - sum(
+			increase(bullmq_job_duration_seconds_bucket{ queue=~"file-export", le="120" }[{{ .window }}])
+		) OR on() vector(0))
+	)
+	/
+	((
+		(sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export" }[{{ .window }}])
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Queue deadlines and in-flight metric provided should count overdue running jobs per queue.": {
+			options: map[string]string{
+				"metricName":         "bullmq_job_duration_seconds",
+				"queueLabelName":     "queue",
+				"queueDeadlines":     "file-export:120, mt-batch:600",
+				"inFlightMetricName": "bullmq_job_started_timestamp_seconds",
+				"jobNameLabelName":   "name",
+				"jobNameLabelValue":  "xliff.*",
+			},
+			expQuery: `
+(
+	(
+		(sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export", name=~"xliff.*" }[{{ .window }}])
+		) - sum(
+			increase(bullmq_job_duration_seconds_bucket{ queue=~"file-export", name=~"xliff.*", le="120" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(count(
+			(timestamp(bullmq_job_started_timestamp_seconds{ queue=~"file-export", name=~"xliff.*" }) - bullmq_job_started_timestamp_seconds{ queue=~"file-export", name=~"xliff.*" }) > 120
+		) OR on() vector(0))
+		+
+		(sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"mt-batch", name=~"xliff.*" }[{{ .window }}])
+		) - sum(
+			increase(bullmq_job_duration_seconds_bucket{ queue=~"mt-batch", name=~"xliff.*", le="600" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(count(
+			(timestamp(bullmq_job_started_timestamp_seconds{ queue=~"mt-batch", name=~"xliff.*" }) - bullmq_job_started_timestamp_seconds{ queue=~"mt-batch", name=~"xliff.*" }) > 600
+		) OR on() vector(0))
+	)
+	/
+	((
+		(sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export|mt-batch", name=~"xliff.*" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(count(
+			(timestamp(bullmq_job_started_timestamp_seconds{ queue=~"file-export", name=~"xliff.*" }) - bullmq_job_started_timestamp_seconds{ queue=~"file-export", name=~"xliff.*" }) > 120
+		) OR on() vector(0))
+		+
+		(count(
+			(timestamp(bullmq_job_started_timestamp_seconds{ queue=~"mt-batch", name=~"xliff.*" }) - bullmq_job_started_timestamp_seconds{ queue=~"mt-batch", name=~"xliff.*" }) > 600
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance rate window provided should scale the increases of every step to a minute.": {
+			options: map[string]string{
+				"metricName":            "bullmq_job_duration_seconds",
+				"queueLabelName":        "queue",
+				"queues":                "file-export",
+				"deadline":              "120",
+				"maintenanceSeries":     `maintenance_mode{app="export"}`,
+				"maintenanceRateWindow": "2m",
+			},
+			expQuery: `
+(
+	(
+		(sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export" }[2m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m]) / 2 - sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_bucket{ queue=~"file-export", le="120" }[2m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m]) / 2 OR on() vector(0))
+	)
+	/
+	((
+		(sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_count{ queue=~"file-export" }[2m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m]) / 2 OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series and offset provided should be applied.": {
+			options: map[string]string{
+				"metricName":        "bullmq_job_duration_seconds",
+				"queueLabelName":    "queue",
+				"queues":            "file-export",
+				"deadline":          "120",
+				"additionalLabels":  `env="live"`,
+				"maintenanceSeries": `maintenance_mode{app="export"}`,
+				"offset":            "5m",
+			},
+			expQuery: `
+(
+	(
+		(sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_count{ env="live", queue=~"file-export" }[5m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m] offset 5m) / 5 - sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_bucket{ env="live", queue=~"file-export", le="120" }[5m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m] offset 5m) / 5 OR on() vector(0))
+	)
+	/
+	((
+		(sum_over_time((sum(
+			increase(bullmq_job_duration_seconds_count{ env="live", queue=~"file-export" }[5m])
+		) unless on() (maintenance_mode{app="export"}))[{{ .window }}:1m] offset 5m) / 5 OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := deadline.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package success
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/jobs/success"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	({{ .stepSum }}sum(
+		rate({{ .badMetricName }}{ {{ .selector }}{{ .exhaustedRetriesLabels }} }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} OR on() vector(0))
+	/
+	((
+		({{ .stepSum }}sum(
+			rate({{ .goodMetricName }}{ {{ .selector }} }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} OR on() vector(0))
+		+
+		({{ .stepSum }}sum(
+			rate({{ .badMetricName }}{ {{ .selector }}{{ .exhaustedRetriesLabels }} }[{{ .rateWindow }}]{{ .offset }})
+		){{ .stepEnd }} OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the ratio of failed jobs based on separate completed and failed job
+// counters of queue workers.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	goodMetricName, err := getMetricName(options, "goodMetricName")
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	badMetricName, err := getMetricName(options, "badMetricName")
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	queueLabelName, err := getLabelName(options, "queueLabelName")
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	queueLabelValue, err := getQueueLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	jobNameSelector, err := getJobNameSelector(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"goodMetricName":         goodMetricName,
+		"badMetricName":          badMetricName,
+		"selector":               fmt.Sprintf(`%s%s=~"%s"%s`, getAdditionalLabels(options), queueLabelName, queueLabelValue, jobNameSelector),
+		"exhaustedRetriesLabels": getExhaustedRetriesLabels(options),
+	}
+	for k, v := range steps {
+		data[k] = v
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+// getExhaustedRetriesLabels returns the label matchers that select the failed jobs which exhausted their retries
+// (e.g. `final="true"`). Failures that will be retried are then left out of both sides of the ratio.
+func getExhaustedRetriesLabels(options map[string]string) string {
+	labels := options["exhaustedRetriesLabels"]
+	labels = strings.Trim(labels, "{}, ")
+
+	if labels != "" {
+		labels = ", " + labels
+	}
+
+	return labels
+}
+
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+func getMetricName(options map[string]string, key string) (string, error) {
+	metricName := options[key]
+	metricName = strings.TrimSpace(metricName)
+
+	if metricName == "" {
+		return "", fmt.Errorf("'%s' is required", key)
+	}
+
+	if !metricNameRegexp.MatchString(metricName) {
+		return "", fmt.Errorf("invalid metric name for '%s': %q", key, metricName)
+	}
+
+	return metricName, nil
+}
+
+func getLabelName(options map[string]string, key string) (string, error) {
+	label := options[key]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'%s' name is required", key)
+	}
+
+	return label, nil
+}
+
+func getQueueLabelValue(options map[string]string) (string, error) {
+	value := options["queueLabelValue"]
+	value = strings.TrimSpace(value)
+
+	values := options["queues"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'queueLabelValue' and 'queues' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("queues", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'queueLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'queueLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+func getJobNameSelector(options map[string]string) (string, error) {
+	value := options["jobNameLabelValue"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return "", nil
+	}
+
+	label, err := getLabelName(options, "jobNameLabelName")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'jobNameLabelValue': %w", err)
+	}
+
+	return fmt.Sprintf(`, %s=~"%s"`, label, value), nil
+}
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenanceRateWindow"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenanceRateWindow': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
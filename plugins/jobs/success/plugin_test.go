@@ -0,0 +1,185 @@
+package success_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	success "github.com/lokalise/common-sloth-sli-plugins/plugins/jobs/success"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without bad metric name, should fail.": {
+			options: map[string]string{
+				"goodMetricName":  "bullmq_jobs_completed_total",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-import",
+			},
+			expErr: true,
+		},
+
+		"An invalid metric name, should fail.": {
+			options: map[string]string{
+				"goodMetricName":  "bullmq_jobs_completed_total",
+				"badMetricName":   "bullmq-jobs-failed",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-import",
+			},
+			expErr: true,
+		},
+
+		"Without queue, should fail.": {
+			options: map[string]string{
+				"goodMetricName": "bullmq_jobs_completed_total",
+				"badMetricName":  "bullmq_jobs_failed_total",
+				"queueLabelName": "queue",
+			},
+			expErr: true,
+		},
+
+		"Both queue regex and queue list provided, should fail.": {
+			options: map[string]string{
+				"goodMetricName":  "bullmq_jobs_completed_total",
+				"badMetricName":   "bullmq_jobs_failed_total",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-import",
+				"queues":          "file-import,file-export",
+			},
+			expErr: true,
+		},
+
+		"Job name without its label name, should fail.": {
+			options: map[string]string{
+				"goodMetricName":    "bullmq_jobs_completed_total",
+				"badMetricName":     "bullmq_jobs_failed_total",
+				"queueLabelName":    "queue",
+				"queueLabelValue":   "file-import",
+				"jobNameLabelValue": "xliff",
+			},
+			expErr: true,
+		},
+
+		"An invalid job name, should fail.": {
+			options: map[string]string{
+				"goodMetricName":    "bullmq_jobs_completed_total",
+				"badMetricName":     "bullmq_jobs_failed_total",
+				"queueLabelName":    "queue",
+				"queueLabelValue":   "file-import",
+				"jobNameLabelName":  "name",
+				"jobNameLabelValue": "([xyz",
+			},
+			expErr: true,
+		},
+
+		"Metric names and queue provided should return the failed job ratio.": {
+			options: map[string]string{
+				"goodMetricName":  "bullmq_jobs_completed_total",
+				"badMetricName":   "bullmq_jobs_failed_total",
+				"queueLabelName":  "queue",
+				"queueLabelValue": "file-import",
+			},
+			expQuery: `
+(
+	(sum(
+		rate(bullmq_jobs_failed_total{ queue=~"file-import" }[{{ .window }}])
+	) OR on() vector(0))
+	/
+	((
+		(sum(
+			rate(bullmq_jobs_completed_total{ queue=~"file-import" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(sum(
+			rate(bullmq_jobs_failed_total{ queue=~"file-import" }[{{ .window }}])
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Job name and exhausted retries labels provided should only count the final failures of those jobs.": {
+			options: map[string]string{
+				"goodMetricName":         "sidekiq_jobs_completed_total",
+				"badMetricName":          "sidekiq_jobs_failed_total",
+				"queueLabelName":         "queue",
+				"queues":                 "exports,imports",
+				"jobNameLabelName":       "worker",
+				"jobNameLabelValue":      "Export.*",
+				"exhaustedRetriesLabels": `{final="true"}`,
+				"additionalLabels":       `env="live"`,
+			},
+			expQuery: `
+(
+	(sum(
+		rate(sidekiq_jobs_failed_total{ env="live", queue=~"exports|imports", worker=~"Export.*", final="true" }[{{ .window }}])
+	) OR on() vector(0))
+	/
+	((
+		(sum(
+			rate(sidekiq_jobs_completed_total{ env="live", queue=~"exports|imports", worker=~"Export.*" }[{{ .window }}])
+		) OR on() vector(0))
+		+
+		(sum(
+			rate(sidekiq_jobs_failed_total{ env="live", queue=~"exports|imports", worker=~"Export.*", final="true" }[{{ .window }}])
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series and offset provided should be applied.": {
+			options: map[string]string{
+				"goodMetricName":    "bullmq_jobs_completed_total",
+				"badMetricName":     "bullmq_jobs_failed_total",
+				"queueLabelName":    "queue",
+				"queueLabelValue":   "file-import",
+				"maintenanceSeries": `maintenance_mode{app="import"}`,
+				"offset":            "5m",
+			},
+			expQuery: `
+(
+	(sum_over_time((sum(
+		rate(bullmq_jobs_failed_total{ queue=~"file-import" }[5m])
+	) unless on() (maintenance_mode{app="import"}))[{{ .window }}:1m] offset 5m) OR on() vector(0))
+	/
+	((
+		(sum_over_time((sum(
+			rate(bullmq_jobs_completed_total{ queue=~"file-import" }[5m])
+		) unless on() (maintenance_mode{app="import"}))[{{ .window }}:1m] offset 5m) OR on() vector(0))
+		+
+		(sum_over_time((sum(
+			rate(bullmq_jobs_failed_total{ queue=~"file-import" }[5m])
+		) unless on() (maintenance_mode{app="import"}))[{{ .window }}:1m] offset 5m) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := success.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
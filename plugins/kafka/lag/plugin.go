@@ -0,0 +1,236 @@
+package lag
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/kafka/lag"
+)
+
+var lagQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+avg_over_time(
+	(
+		sum(max_over_time(kafka_consumergroup_lag{{"{"}}{{ .additionalLabels }}consumergroup=~"{{ .consumerGroup }}", topic=~"{{ .topic }}"{{"}"}}[1m])) > bool {{ .lagThreshold }}{{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+) OR on() vector(0)
+`))
+
+var lagSecondsQueryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+avg_over_time(
+	(
+		(
+			sum(max_over_time(kafka_consumergroup_lag{{"{"}}{{ .additionalLabels }}consumergroup=~"{{ .consumerGroup }}", topic=~"{{ .topic }}"{{"}"}}[1m]))
+			/
+			sum(rate(kafka_consumergroup_current_offset{{"{"}}{{ .additionalLabels }}consumergroup=~"{{ .consumerGroup }}", topic=~"{{ .topic }}"{{"}"}}[{{ .consumeRateWindow }}]))
+		) > bool {{ .lagSecondsThreshold }}{{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the fraction of minutes in which a consumer group lagged behind,
+// based on kafka-exporter metrics. The lag is either the number of messages or the time needed to consume them at
+// the current consume rate.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	consumerGroup, err := getConsumerGroup(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	topic, err := getTopic(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	lagThreshold, err := getThreshold(options, "lagThreshold")
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	lagSecondsThreshold, err := getThreshold(options, "lagSecondsThreshold")
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	if (lagThreshold == "") == (lagSecondsThreshold == "") {
+		return "", fmt.Errorf("Error parsing options: exactly one of 'lagThreshold' and 'lagSecondsThreshold' is required")
+	}
+
+	consumeRateWindow, err := getConsumeRateWindow(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"consumerGroup":       consumerGroup,
+		"topic":               topic,
+		"lagThreshold":        lagThreshold,
+		"lagSecondsThreshold": lagSecondsThreshold,
+		"consumeRateWindow":   consumeRateWindow,
+		"additionalLabels":    getAdditionalLabels(options),
+		"maintenance":         getMaintenanceFilter(options),
+		"offset":              offset,
+	}
+
+	queryTpl := lagQueryTpl
+	if lagSecondsThreshold != "" {
+		queryTpl = lagSecondsQueryTpl
+	}
+
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getConsumerGroup(options map[string]string) (string, error) {
+	value := options["consumerGroup"]
+	value = strings.TrimSpace(value)
+
+	values := options["consumerGroups"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'consumerGroup' and 'consumerGroups' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("consumerGroups", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'consumerGroup' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'consumerGroup': %w", err)
+	}
+
+	return value, nil
+}
+
+func getTopic(options map[string]string) (string, error) {
+	value := options["topic"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return ".*", nil
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'topic': %w", err)
+	}
+
+	return value, nil
+}
+
+func getThreshold(options map[string]string, key string) (string, error) {
+	threshold := options[key]
+	threshold = strings.TrimSpace(threshold)
+
+	if threshold == "" {
+		return "", nil
+	}
+
+	value, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a valid number: %w", key, err)
+	}
+
+	if value < 0 {
+		return "", fmt.Errorf("'%s' must not be negative", key)
+	}
+
+	return threshold, nil
+}
+
+func getConsumeRateWindow(options map[string]string) (string, error) {
+	window := options["consumeRateWindow"]
+	window = strings.TrimSpace(window)
+
+	if window == "" {
+		return "5m", nil
+	}
+
+	if !durationRegexp.MatchString(window) {
+		return "", fmt.Errorf("invalid duration for 'consumeRateWindow': %q", window)
+	}
+
+	return window, nil
+}
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
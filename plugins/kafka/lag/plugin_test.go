@@ -0,0 +1,118 @@
+package lag_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	lag "github.com/lokalise/common-sloth-sli-plugins/plugins/kafka/lag"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without consumer group, should fail.": {
+			options: map[string]string{"lagThreshold": "1000"},
+			expErr:  true,
+		},
+
+		"An invalid consumer group, should fail.": {
+			options: map[string]string{
+				"consumerGroup": "([xyz",
+				"lagThreshold":  "1000",
+			},
+			expErr: true,
+		},
+
+		"Without threshold, should fail.": {
+			options: map[string]string{"consumerGroup": "import-worker"},
+			expErr:  true,
+		},
+
+		"Both thresholds provided, should fail.": {
+			options: map[string]string{
+				"consumerGroup":       "import-worker",
+				"lagThreshold":        "1000",
+				"lagSecondsThreshold": "120",
+			},
+			expErr: true,
+		},
+
+		"A negative threshold, should fail.": {
+			options: map[string]string{
+				"consumerGroup": "import-worker",
+				"lagThreshold":  "-1",
+			},
+			expErr: true,
+		},
+
+		"An invalid consume rate window, should fail.": {
+			options: map[string]string{
+				"consumerGroup":       "import-worker",
+				"lagSecondsThreshold": "120",
+				"consumeRateWindow":   "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Lag threshold provided should mark minutes with a summed lag above it as bad.": {
+			options: map[string]string{
+				"consumerGroup": "import-worker",
+				"lagThreshold":  "1000",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		sum(max_over_time(kafka_consumergroup_lag{consumergroup=~"import-worker", topic=~".*"}[1m])) > bool 1000
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+
+		"Lag seconds threshold provided should estimate the lag in seconds from the consume rate.": {
+			options: map[string]string{
+				"consumerGroups":      "import-worker,export-worker",
+				"topic":               "files-.*",
+				"lagSecondsThreshold": "120",
+				"additionalLabels":    `env="live"`,
+				"maintenanceSeries":   `maintenance_active{service="kafka"} == 1`,
+			},
+			expQuery: `
+avg_over_time(
+	(
+		(
+			sum(max_over_time(kafka_consumergroup_lag{env="live", consumergroup=~"import-worker|export-worker", topic=~"files-.*"}[1m]))
+			/
+			sum(rate(kafka_consumergroup_current_offset{env="live", consumergroup=~"import-worker|export-worker", topic=~"files-.*"}[5m]))
+		) > bool 120 unless on() (maintenance_active{service="kafka"} == 1)
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := lag.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
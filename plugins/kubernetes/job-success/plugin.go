@@ -0,0 +1,266 @@
+package jobsuccess
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/kubernetes/job-success"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+(
+	(
+		(count(
+			(max_over_time(kube_job_status_failed{ {{ .selector }} }[{{"{{ .window }}"}}]{{ .offset }}) > 0)
+			unless on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ {{ .selector }} }[{{"{{ .window }}"}}]{{ .offset }}) > 0)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ {{ .selector }}, owner_kind="CronJob", owner_name=~"{{ .cronJob }}" }[{{"{{ .window }}"}}]{{ .offset }})
+			unless on(namespace, job_name) {{ .finishedBefore }}
+{{- if .maintenance }}
+			unless on(namespace, job_name) max_over_time(((kube_job_status_start_time{ {{ .selector }} } > time() - 60) and on() ({{ .maintenance }}))[{{"{{ .window }}"}}:1m]{{ .offset }})
+{{- end }}
+		) OR on() vector(0))
+{{- if .missedScheduleThreshold }}
+		+
+		(count(
+			max_over_time((time() - kube_cronjob_status_last_schedule_time{ {{ .selector }}, cronjob=~"{{ .cronJob }}" } > {{ .missedScheduleThreshold }}{{ .sliceMaintenance }})[{{"{{ .window }}"}}:1m]{{ .offset }})
+			unless on(namespace, cronjob) (kube_cronjob_spec_suspend{ {{ .selector }}, cronjob=~"{{ .cronJob }}" }{{ .offset }} == 1)
+		) OR on() vector(0))
+{{- end }}
+	)
+	/
+	((
+		(count(
+			(
+				(max_over_time(kube_job_status_failed{ {{ .selector }} }[{{"{{ .window }}"}}]{{ .offset }}) > 0)
+				or on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ {{ .selector }} }[{{"{{ .window }}"}}]{{ .offset }}) > 0)
+			)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ {{ .selector }}, owner_kind="CronJob", owner_name=~"{{ .cronJob }}" }[{{"{{ .window }}"}}]{{ .offset }})
+			unless on(namespace, job_name) {{ .finishedBefore }}
+{{- if .maintenance }}
+			unless on(namespace, job_name) max_over_time(((kube_job_status_start_time{ {{ .selector }} } > time() - 60) and on() ({{ .maintenance }}))[{{"{{ .window }}"}}:1m]{{ .offset }})
+{{- end }}
+		) OR on() vector(0))
+{{- if .missedScheduleThreshold }}
+		+
+		(count(
+			max_over_time((time() - kube_cronjob_status_last_schedule_time{ {{ .selector }}, cronjob=~"{{ .cronJob }}" } > {{ .missedScheduleThreshold }}{{ .sliceMaintenance }})[{{"{{ .window }}"}}:1m]{{ .offset }})
+			unless on(namespace, cronjob) (kube_cronjob_spec_suspend{ {{ .selector }}, cronjob=~"{{ .cronJob }}" }{{ .offset }} == 1)
+		) OR on() vector(0))
+{{- end }}
+	) > 0)
+) OR on() vector(0)
+`))
+
+// SLIPlugin will return a query that will return the ratio of failed runs of CronJobs based on kube-state-metrics.
+// A run is the Job created by the CronJob, it failed when it has failed pods and no succeeded one. When a missed
+// schedule threshold is given, every CronJob that was not scheduled for longer than that at some point of the window
+// counts as one more failed run, unless it is suspended. Jobs that had already finished when the window started are
+// kept in the CronJob history, they are left out so every run is only counted in the windows it started or finished
+// in. Runs that started and minutes that passed while the maintenance series was present are left out as well.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	namespace, err := getNamespace(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	cronJob, err := getCronJob(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	missedScheduleThreshold, err := getMissedScheduleThreshold(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	selector := fmt.Sprintf(`%snamespace=~"%s"`, getAdditionalLabels(options), namespace)
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"selector":                selector,
+		"finishedBefore":          getFinishedBefore(selector, offset),
+		"cronJob":                 cronJob,
+		"missedScheduleThreshold": missedScheduleThreshold,
+		"maintenance":             getMaintenance(options),
+		"sliceMaintenance":        "",
+		"offset":                  offset,
+	}
+	if data["maintenance"] != "" {
+		data["sliceMaintenance"] = fmt.Sprintf(" unless on() (%s)", data["maintenance"])
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// getFinishedBefore returns the Jobs that were complete or failed at the start of the window. An offset can't be
+// added to the one of the window, so the selectors are evaluated in a subquery moved by it instead.
+func getFinishedBefore(selector, offset string) string {
+	finished := fmt.Sprintf(
+		`(kube_job_complete{ %[1]s, condition="true" } offset {{ .window }} == 1 or kube_job_failed{ %[1]s, condition="true" } offset {{ .window }} == 1)`,
+		selector,
+	)
+
+	if offset == "" {
+		return finished
+	}
+
+	return fmt.Sprintf("last_over_time(%s[1m:1m]%s)", finished, offset)
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getNamespace(options map[string]string) (string, error) {
+	value := options["namespace"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return "", fmt.Errorf("'namespace' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'namespace': %w", err)
+	}
+
+	return value, nil
+}
+
+func getCronJob(options map[string]string) (string, error) {
+	value := options["cronJob"]
+	value = strings.TrimSpace(value)
+
+	values := options["cronJobs"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'cronJob' and 'cronJobs' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("cronJobs", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'cronJob' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'cronJob': %w", err)
+	}
+
+	return value, nil
+}
+
+// getMissedScheduleThreshold takes the threshold as a duration and returns it in seconds, the unit of the schedule
+// timestamps. It should be longer than the interval of the schedule.
+func getMissedScheduleThreshold(options map[string]string) (string, error) {
+	threshold := options["missedScheduleThreshold"]
+	threshold = strings.TrimSpace(threshold)
+
+	if threshold == "" {
+		return "", nil
+	}
+
+	seconds, ok := durationSeconds(threshold)
+	if !ok || seconds <= 0 {
+		return "", fmt.Errorf("invalid duration for 'missedScheduleThreshold': %q", threshold)
+	}
+
+	return strconv.FormatFloat(seconds, 'f', -1, 64), nil
+}
+
+func getMaintenance(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	return strings.TrimSpace(maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
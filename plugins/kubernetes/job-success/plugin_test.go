@@ -0,0 +1,173 @@
+package jobsuccess_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	jobsuccess "github.com/lokalise/common-sloth-sli-plugins/plugins/kubernetes/job-success"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without namespace, should fail.": {
+			options: map[string]string{"cronJob": "glossary-sync"},
+			expErr:  true,
+		},
+
+		"Without CronJob, should fail.": {
+			options: map[string]string{"namespace": "glossary"},
+			expErr:  true,
+		},
+
+		"Both CronJob regex and CronJob list provided, should fail.": {
+			options: map[string]string{
+				"namespace": "glossary",
+				"cronJob":   "glossary-sync",
+				"cronJobs":  "glossary-sync",
+			},
+			expErr: true,
+		},
+
+		"An invalid missed schedule threshold, should fail.": {
+			options: map[string]string{
+				"namespace":               "glossary",
+				"cronJob":                 "glossary-sync",
+				"missedScheduleThreshold": "1 day",
+			},
+			expErr: true,
+		},
+
+		"Namespace and CronJob provided should return the ratio of failed runs.": {
+			options: map[string]string{
+				"namespace": "glossary",
+				"cronJob":   "glossary-sync",
+			},
+			expQuery: `
+(
+	(
+		(count(
+			(max_over_time(kube_job_status_failed{ namespace=~"glossary" }[{{ .window }}]) > 0)
+			unless on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ namespace=~"glossary" }[{{ .window }}]) > 0)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ namespace=~"glossary", owner_kind="CronJob", owner_name=~"glossary-sync" }[{{ .window }}])
+			unless on(namespace, job_name) (kube_job_complete{ namespace=~"glossary", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ namespace=~"glossary", condition="true" } offset {{ .window }} == 1)
+		) OR on() vector(0))
+	)
+	/
+	((
+		(count(
+			(
+				(max_over_time(kube_job_status_failed{ namespace=~"glossary" }[{{ .window }}]) > 0)
+				or on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ namespace=~"glossary" }[{{ .window }}]) > 0)
+			)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ namespace=~"glossary", owner_kind="CronJob", owner_name=~"glossary-sync" }[{{ .window }}])
+			unless on(namespace, job_name) (kube_job_complete{ namespace=~"glossary", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ namespace=~"glossary", condition="true" } offset {{ .window }} == 1)
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Jobs that had already finished when the window started should be excluded.": {
+			options: map[string]string{
+				"namespace":        "glossary",
+				"cronJobs":         "glossary-sync,glossary-cleanup",
+				"additionalLabels": `cluster="eu-1"`,
+			},
+			expQuery: `
+(
+	(
+		(count(
+			(max_over_time(kube_job_status_failed{ cluster="eu-1", namespace=~"glossary" }[{{ .window }}]) > 0)
+			unless on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ cluster="eu-1", namespace=~"glossary" }[{{ .window }}]) > 0)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ cluster="eu-1", namespace=~"glossary", owner_kind="CronJob", owner_name=~"glossary-sync|glossary-cleanup" }[{{ .window }}])
+			unless on(namespace, job_name) (kube_job_complete{ cluster="eu-1", namespace=~"glossary", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ cluster="eu-1", namespace=~"glossary", condition="true" } offset {{ .window }} == 1)
+		) OR on() vector(0))
+	)
+	/
+	((
+		(count(
+			(
+				(max_over_time(kube_job_status_failed{ cluster="eu-1", namespace=~"glossary" }[{{ .window }}]) > 0)
+				or on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ cluster="eu-1", namespace=~"glossary" }[{{ .window }}]) > 0)
+			)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ cluster="eu-1", namespace=~"glossary", owner_kind="CronJob", owner_name=~"glossary-sync|glossary-cleanup" }[{{ .window }}])
+			unless on(namespace, job_name) (kube_job_complete{ cluster="eu-1", namespace=~"glossary", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ cluster="eu-1", namespace=~"glossary", condition="true" } offset {{ .window }} == 1)
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Missed schedule threshold, maintenance series and offset provided should count missed schedules as failed runs.": {
+			options: map[string]string{
+				"namespace":               "ops",
+				"cronJobs":                "db-backup,s3-backup",
+				"missedScheduleThreshold": "25h",
+				"maintenanceSeries":       `maintenance_mode{app="backup"}`,
+				"offset":                  "5m",
+			},
+			expQuery: `
+(
+	(
+		(count(
+			(max_over_time(kube_job_status_failed{ namespace=~"ops" }[{{ .window }}] offset 5m) > 0)
+			unless on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ namespace=~"ops" }[{{ .window }}] offset 5m) > 0)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ namespace=~"ops", owner_kind="CronJob", owner_name=~"db-backup|s3-backup" }[{{ .window }}] offset 5m)
+			unless on(namespace, job_name) last_over_time((kube_job_complete{ namespace=~"ops", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ namespace=~"ops", condition="true" } offset {{ .window }} == 1)[1m:1m] offset 5m)
+			unless on(namespace, job_name) max_over_time(((kube_job_status_start_time{ namespace=~"ops" } > time() - 60) and on() (maintenance_mode{app="backup"}))[{{ .window }}:1m] offset 5m)
+		) OR on() vector(0))
+		+
+		(count(
+			max_over_time((time() - kube_cronjob_status_last_schedule_time{ namespace=~"ops", cronjob=~"db-backup|s3-backup" } > 90000 unless on() (maintenance_mode{app="backup"}))[{{ .window }}:1m] offset 5m)
+			unless on(namespace, cronjob) (kube_cronjob_spec_suspend{ namespace=~"ops", cronjob=~"db-backup|s3-backup" } offset 5m == 1)
+		) OR on() vector(0))
+	)
+	/
+	((
+		(count(
+			(
+				(max_over_time(kube_job_status_failed{ namespace=~"ops" }[{{ .window }}] offset 5m) > 0)
+				or on(namespace, job_name) (max_over_time(kube_job_status_succeeded{ namespace=~"ops" }[{{ .window }}] offset 5m) > 0)
+			)
+			and on(namespace, job_name) max_over_time(kube_job_owner{ namespace=~"ops", owner_kind="CronJob", owner_name=~"db-backup|s3-backup" }[{{ .window }}] offset 5m)
+			unless on(namespace, job_name) last_over_time((kube_job_complete{ namespace=~"ops", condition="true" } offset {{ .window }} == 1 or kube_job_failed{ namespace=~"ops", condition="true" } offset {{ .window }} == 1)[1m:1m] offset 5m)
+			unless on(namespace, job_name) max_over_time(((kube_job_status_start_time{ namespace=~"ops" } > time() - 60) and on() (maintenance_mode{app="backup"}))[{{ .window }}:1m] offset 5m)
+		) OR on() vector(0))
+		+
+		(count(
+			max_over_time((time() - kube_cronjob_status_last_schedule_time{ namespace=~"ops", cronjob=~"db-backup|s3-backup" } > 90000 unless on() (maintenance_mode{app="backup"}))[{{ .window }}:1m] offset 5m)
+			unless on(namespace, cronjob) (kube_cronjob_spec_suspend{ namespace=~"ops", cronjob=~"db-backup|s3-backup" } offset 5m == 1)
+		) OR on() vector(0))
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := jobsuccess.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package workloadavailability
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/kubernetes/workload-availability"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+avg_over_time(
+	(
+		max(
+			{{ .availableMetricName }}{ {{ .selector }} }
+{{- if .requiredReplicas }} < bool {{ .requiredReplicas }}
+{{- else }}
+			< bool on(namespace, {{ .workloadLabelName }})
+			{{ .specMetricName }}{ {{ .selector }} } * {{ .requiredFraction }}
+{{- end }}
+		){{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+) OR on() vector(0)
+`))
+
+// workloadKind holds the kube-state-metrics names of a kind of workload.
+type workloadKind struct {
+	availableMetricName string
+	specMetricName      string
+	labelName           string
+}
+
+var workloadKinds = map[string]workloadKind{
+	"deployment": {
+		availableMetricName: "kube_deployment_status_replicas_available",
+		specMetricName:      "kube_deployment_spec_replicas",
+		labelName:           "deployment",
+	},
+	"statefulset": {
+		availableMetricName: "kube_statefulset_status_replicas_available",
+		specMetricName:      "kube_statefulset_replicas",
+		labelName:           "statefulset",
+	},
+}
+
+// SLIPlugin will return a query that will return the fraction of minutes in which a workload had fewer available
+// replicas than required based on kube-state-metrics.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	kind, err := getWorkloadKind(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	namespace, err := getNamespace(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	workload, err := getWorkload(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	requiredReplicas, requiredFraction, err := getRequirement(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"availableMetricName": kind.availableMetricName,
+		"specMetricName":      kind.specMetricName,
+		"workloadLabelName":   kind.labelName,
+		"selector":            fmt.Sprintf(`%snamespace=~"%s", %s=~"%s"`, getAdditionalLabels(options), namespace, kind.labelName, workload),
+		"requiredReplicas":    requiredReplicas,
+		"requiredFraction":    requiredFraction,
+		"maintenance":         getMaintenanceFilter(options),
+		"offset":              offset,
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getWorkloadKind(options map[string]string) (workloadKind, error) {
+	name := options["kind"]
+	name = strings.TrimSpace(name)
+
+	if name == "" {
+		name = "deployment"
+	}
+
+	kind, ok := workloadKinds[name]
+	if !ok {
+		return workloadKind{}, fmt.Errorf("unknown kind %q, must be deployment or statefulset", name)
+	}
+
+	return kind, nil
+}
+
+func getNamespace(options map[string]string) (string, error) {
+	value := options["namespace"]
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return "", fmt.Errorf("'namespace' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'namespace': %w", err)
+	}
+
+	return value, nil
+}
+
+func getWorkload(options map[string]string) (string, error) {
+	value := options["workload"]
+	value = strings.TrimSpace(value)
+
+	values := options["workloads"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'workload' and 'workloads' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("workloads", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'workload' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'workload': %w", err)
+	}
+
+	return value, nil
+}
+
+// getRequirement returns either the number of replicas that have to be available, or the fraction of the desired
+// replicas that has to be available. Exactly one of 'requiredReplicas' and 'requiredPercentage' has to be set.
+func getRequirement(options map[string]string) (string, string, error) {
+	replicas := options["requiredReplicas"]
+	replicas = strings.TrimSpace(replicas)
+
+	percentage := options["requiredPercentage"]
+	percentage = strings.TrimSpace(percentage)
+
+	if replicas != "" && percentage != "" {
+		return "", "", fmt.Errorf("only one of 'requiredReplicas' and 'requiredPercentage' can be set")
+	}
+
+	if replicas != "" {
+		value, err := strconv.Atoi(replicas)
+		if err != nil {
+			return "", "", fmt.Errorf("not a valid number of replicas, can't parse to int: %w", err)
+		}
+
+		if value < 1 {
+			return "", "", fmt.Errorf("invalid 'requiredReplicas' %q, must be at least 1", replicas)
+		}
+
+		return replicas, "", nil
+	}
+
+	if percentage == "" {
+		return "", "", fmt.Errorf("one of 'requiredReplicas' and 'requiredPercentage' is required")
+	}
+
+	value, err := strconv.ParseFloat(percentage, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("not a valid percentage, can't parse to float64: %w", err)
+	}
+
+	if value <= 0 || value > 100 {
+		return "", "", fmt.Errorf("invalid 'requiredPercentage' %q, must be greater than 0 and at most 100", percentage)
+	}
+
+	return "", strconv.FormatFloat(value/100, 'f', -1, 64), nil
+}
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
@@ -0,0 +1,125 @@
+package workloadavailability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	workloadavailability "github.com/lokalise/common-sloth-sli-plugins/plugins/kubernetes/workload-availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without requirement, should fail.": {
+			options: map[string]string{
+				"namespace": "internal",
+				"workload":  "glossary-api",
+			},
+			expErr: true,
+		},
+
+		"Both required replicas and percentage provided, should fail.": {
+			options: map[string]string{
+				"namespace":          "internal",
+				"workload":           "glossary-api",
+				"requiredReplicas":   "2",
+				"requiredPercentage": "50",
+			},
+			expErr: true,
+		},
+
+		"A fractional number of required replicas, should fail.": {
+			options: map[string]string{
+				"namespace":        "internal",
+				"workload":         "glossary-api",
+				"requiredReplicas": "1.5",
+			},
+			expErr: true,
+		},
+
+		"A required percentage above 100, should fail.": {
+			options: map[string]string{
+				"namespace":          "internal",
+				"workload":           "glossary-api",
+				"requiredPercentage": "150",
+			},
+			expErr: true,
+		},
+
+		"An unknown kind, should fail.": {
+			options: map[string]string{
+				"namespace":        "internal",
+				"workload":         "glossary-api",
+				"kind":             "daemonset",
+				"requiredReplicas": "2",
+			},
+			expErr: true,
+		},
+
+		"Required replicas provided should mark minutes with fewer available replicas as bad.": {
+			options: map[string]string{
+				"namespace":        "internal",
+				"workload":         "glossary-api",
+				"requiredReplicas": "2",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		max(
+			kube_deployment_status_replicas_available{ namespace=~"internal", deployment=~"glossary-api" } < bool 2
+		)
+	)[{{ .window }}:1m]
+) OR on() vector(0)
+`,
+		},
+
+		"Required percentage of a StatefulSet provided should compare with the desired replicas.": {
+			options: map[string]string{
+				"namespace":          "internal",
+				"workloads":          "redis-cache",
+				"kind":               "statefulset",
+				"requiredPercentage": "75",
+				"additionalLabels":   `cluster="eu"`,
+				"maintenanceSeries":  `maintenance_mode{app="redis"}`,
+				"offset":             "5m",
+			},
+			expQuery: `
+avg_over_time(
+	(
+		max(
+			kube_statefulset_status_replicas_available{ cluster="eu", namespace=~"internal", statefulset=~"redis-cache" }
+			< bool on(namespace, statefulset)
+			kube_statefulset_replicas{ cluster="eu", namespace=~"internal", statefulset=~"redis-cache" } * 0.75
+		) unless on() (maintenance_mode{app="redis"})
+	)[{{ .window }}:1m] offset 5m
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := workloadavailability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
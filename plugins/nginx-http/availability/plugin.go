@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
 )
@@ -16,28 +17,41 @@ const (
 
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 (
-	sum(
-		rate(nginx_ingress_controller_request_duration_seconds_count{ {{ .filter }}exported_service=~"{{ .serviceName }}", status=~"(5..|429|431)" }[{{"{{ .window }}"}}])
-	)
+	{{ .stepSum }}sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ {{ .filter }}exported_service=~"{{ .serviceName }}", status=~"(5..|429|431)" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }}
 	/
-	(sum(
-		rate(nginx_ingress_controller_request_duration_seconds_count{ {{ .filter }}exported_service=~"{{ .serviceName }}" }[{{"{{ .window }}"}}])
-	) > 0)
+	({{ .stepSum }}sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ {{ .filter }}exported_service=~"{{ .serviceName }}" }[{{ .rateWindow }}]{{ .offset }})
+	){{ .stepEnd }} > 0)
 ) OR on() vector(0)
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 service metrics.
+// SLIPlugin will return a query that will return the availability error based on NGINX ingress controller metrics.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
 	service, err := getServiceName(options)
 	if err != nil {
 		return "", fmt.Errorf("could not get service name: %w", err)
 	}
 
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("could not get offset: %w", err)
+	}
+
+	steps, err := getMaintenanceSteps(options, offset)
+	if err != nil {
+		return "", fmt.Errorf("could not get maintenance steps: %w", err)
+	}
+
 	var b bytes.Buffer
 	data := map[string]string{
 		"filter":      getFilter(options),
 		"serviceName": service,
 	}
+	for k, v := range steps {
+		data[k] = v
+	}
 	err = queryTpl.Execute(&b, data)
 	if err != nil {
 		return "", fmt.Errorf("could not render query template: %w", err)
@@ -60,6 +74,17 @@ func getServiceName(options map[string]string) (string, error) {
 	service := options["service_name_regex"]
 	service = strings.TrimSpace(service)
 
+	services := options["services"]
+	services = strings.TrimSpace(services)
+
+	if service != "" && services != "" {
+		return "", fmt.Errorf("only one of service_name_regex and services can be set")
+	}
+
+	if services != "" {
+		return getExactMatchRegex("services", services)
+	}
+
 	if service == "" {
 		return "", fmt.Errorf("service name is required")
 	}
@@ -71,3 +96,104 @@ func getServiceName(options map[string]string) (string, error) {
 
 	return service, nil
 }
+
+// getMaintenanceSteps splits the aggregations over the window into one minute steps when a maintenance series is set,
+// so only the steps in which it is present are dropped instead of the whole window. Every step takes the rates over
+// the maintenance rate window, it must hold at least two scrapes for rate() to return anything, so it defaults to 5m.
+// The offset moves to the steps to shift the maintenance series together with the metrics.
+func getMaintenanceSteps(options map[string]string, offset string) (map[string]string, error) {
+	maintenance := options["maintenance_series"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return map[string]string{
+			"stepSum":    "",
+			"stepEnd":    "",
+			"rateWindow": "{{ .window }}",
+			"offset":     offset,
+		}, nil
+	}
+
+	rateWindow := options["maintenance_rate_window"]
+	rateWindow = strings.TrimSpace(rateWindow)
+
+	if rateWindow == "" {
+		rateWindow = "5m"
+	}
+
+	seconds, ok := durationSeconds(rateWindow)
+	if !ok || seconds < 60 {
+		return nil, fmt.Errorf("invalid duration for 'maintenance_rate_window': %q, must be at least 1m", rateWindow)
+	}
+
+	return map[string]string{
+		"stepSum":    "sum_over_time((",
+		"stepEnd":    fmt.Sprintf(" unless on() (%s))[{{ .window }}:1m]%s)", maintenance, offset),
+		"rateWindow": rateWindow,
+		"offset":     "",
+	}, nil
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
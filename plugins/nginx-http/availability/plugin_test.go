@@ -98,6 +98,82 @@ func TestSLIPlugin(t *testing.T) {
 		rate(nginx_ingress_controller_request_duration_seconds_count{ k1="v2",k2="v2",exported_service=~"test" }[{{ .window }}])
 	) > 0)
 ) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"maintenance_series": `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+(
+	sum_over_time((sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test", status=~"(5..|429|431)" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+	/
+	(sum_over_time((sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"offset":             "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"offset":             "5m",
+			},
+			expQuery: `
+(
+	sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test", status=~"(5..|429|431)" }[{{ .window }}] offset 5m)
+	)
+	/
+	(sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test" }[{{ .window }}] offset 5m)
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"services":           "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"services": "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"services": "api.v1, web",
+			},
+			expQuery: `
+(
+	sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"api\\.v1|web", status=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"api\\.v1|web" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
 `,
 		},
 	}
@@ -103,6 +103,88 @@ func TestSLIPlugin(t *testing.T) {
 		rate(nginx_ingress_controller_request_duration_seconds_count{ k1="v2",k2="v2",exported_service=~"test" }[{{ .window }}])
 	) > 0)
 ) OR on() vector(1))
+`,
+		},
+
+		"Maintenance series provided should drop the minutes with active maintenance.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"maintenance_series": `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+1 - ((
+	sum_over_time((sum(
+		rate(nginx_ingress_controller_request_duration_seconds_bucket{ exported_service=~"test", le="0.5" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m])
+	/
+	(sum_over_time((sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test" }[5m])
+	) unless on() (maintenance_active{service="test"} == 1))[{{ .window }}:1m]) > 0)
+) OR on() vector(1))
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"offset":             "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should be applied to every selector.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "0.5",
+				"offset":             "5m",
+			},
+			expQuery: `
+1 - ((
+	sum(
+		rate(nginx_ingress_controller_request_duration_seconds_bucket{ exported_service=~"test", le="0.5" }[{{ .window }}] offset 5m)
+	)
+	/
+	(sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"test" }[{{ .window }}] offset 5m)
+	) > 0)
+) OR on() vector(1))
+`,
+		},
+
+		"Both a regex and a list of services, should fail.": {
+			options: map[string]string{
+				"bucket":             "0.5",
+				"service_name_regex": "test",
+				"services":           "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services with an empty value, should fail.": {
+			options: map[string]string{
+				"bucket":   "0.5",
+				"services": "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of services should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"bucket":   "0.5",
+				"services": "api.v1, web",
+			},
+			expQuery: `
+1 - ((
+	sum(
+		rate(nginx_ingress_controller_request_duration_seconds_bucket{ exported_service=~"api\\.v1|web", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(nginx_ingress_controller_request_duration_seconds_count{ exported_service=~"api\\.v1|web" }[{{ .window }}])
+	) > 0)
+) OR on() vector(1))
 `,
 		},
 	}
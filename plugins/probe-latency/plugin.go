@@ -0,0 +1,269 @@
+package probelatency
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const (
+	SLIPluginVersion = "prometheus/v1"
+	SLIPluginID      = "lokalise/probe-latency"
+)
+
+var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
+max(avg_over_time(
+	(
+		avg_over_time(({{ .metricName }}{{"{"}}{{ .additionalLabels }}{{ .ingressLabelName }}=~"{{ .ingressLabelValue }}"{{ .phase }}{{"}"}} > bool {{ .threshold }})[1m:{{ .probeInterval }}]){{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
+)) OR on() vector(0)
+`))
+
+// phases holds the phases of probe_http_duration_seconds.
+var phases = []string{"resolve", "connect", "tls", "processing", "transfer"}
+
+// SLIPlugin will return a query that will return the fraction of probes slower than the threshold based on blackbox
+// exporter probe metrics. Every minute counts with the fraction of its probes that were slower, so a single slow probe
+// doesn't mark the whole minute as bad. The probes are read at the probe interval, it should match the scrape interval
+// of the probes so none of them is skipped.
+func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
+	ingressLabelName, err := getIngressLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	ingressLabelValue, err := getIngressLabelValue(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	threshold, err := getThreshold(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	phase, err := getPhase(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	probeInterval, err := getProbeInterval(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
+	metricName := "probe_duration_seconds"
+	if phase != "" {
+		metricName = "probe_http_duration_seconds"
+		phase = fmt.Sprintf(`, phase="%s"`, phase)
+	}
+
+	var b bytes.Buffer
+	data := map[string]string{
+		"metricName":        metricName,
+		"ingressLabelName":  ingressLabelName,
+		"ingressLabelValue": ingressLabelValue,
+		"phase":             phase,
+		"threshold":         threshold,
+		"probeInterval":     probeInterval,
+		"additionalLabels":  getAdditionalLabels(options),
+		"maintenance":       getMaintenanceFilter(options),
+		"offset":            offset,
+	}
+	err = queryTpl.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("could not render query template: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+func getAdditionalLabels(options map[string]string) string {
+	labels := options["additionalLabels"]
+	labels = strings.Trim(labels, "{},")
+
+	if labels != "" {
+		labels += ", "
+	}
+
+	return labels
+}
+
+func getIngressLabelName(options map[string]string) (string, error) {
+	label := options["ingressLabelName"]
+	label = strings.TrimSpace(label)
+
+	if label == "" {
+		return "", fmt.Errorf("'ingressLabelName' name is required")
+	}
+
+	return label, nil
+}
+
+func getIngressLabelValue(options map[string]string) (string, error) {
+	value := options["ingressLabelValue"]
+	value = strings.TrimSpace(value)
+
+	values := options["ingresses"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'ingressLabelValue' and 'ingresses' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("ingresses", values)
+	}
+
+	if value == "" {
+		return "", fmt.Errorf("'ingressLabelValue' is required")
+	}
+
+	_, err := regexp.Compile(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex for 'ingressLabelValue': %w", err)
+	}
+
+	return value, nil
+}
+
+// getThreshold validates the latency threshold in seconds.
+func getThreshold(options map[string]string) (string, error) {
+	threshold := options["latencyThreshold"]
+	threshold = strings.TrimSpace(threshold)
+
+	if threshold == "" {
+		return "", fmt.Errorf("'latencyThreshold' is required")
+	}
+
+	value, err := strconv.ParseFloat(threshold, 64)
+	if err != nil {
+		return "", fmt.Errorf("not a valid 'latencyThreshold', can't parse to float64: %w", err)
+	}
+
+	if value <= 0 {
+		return "", fmt.Errorf("invalid 'latencyThreshold' %q, must be greater than 0", threshold)
+	}
+
+	return threshold, nil
+}
+
+func getPhase(options map[string]string) (string, error) {
+	phase := options["phase"]
+	phase = strings.TrimSpace(phase)
+
+	if phase == "" {
+		return "", nil
+	}
+
+	for _, p := range phases {
+		if phase == p {
+			return phase, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown phase %q, must be one of %s", phase, strings.Join(phases, ", "))
+}
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+// durationUnits holds the length in seconds of the units captured by durationRegexp, in the order of the groups.
+var durationUnits = []float64{365 * 24 * 60 * 60, 7 * 24 * 60 * 60, 24 * 60 * 60, 60 * 60, 60, 1, 0.001}
+
+// durationSeconds converts a Prometheus duration to seconds.
+func durationSeconds(duration string) (float64, bool) {
+	matches := durationRegexp.FindStringSubmatch(duration)
+	if matches == nil {
+		return 0, false
+	}
+
+	var seconds float64
+	for i, unit := range durationUnits {
+		value := matches[2*i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		seconds += n * unit
+	}
+
+	return seconds, true
+}
+
+// getProbeInterval returns the step the probes of every minute are read at, it defaults to the 15s scrape interval
+// commonly used for blackbox exporter probes.
+func getProbeInterval(options map[string]string) (string, error) {
+	interval := options["probeInterval"]
+	interval = strings.TrimSpace(interval)
+
+	if interval == "" {
+		return "15s", nil
+	}
+
+	seconds, ok := durationSeconds(interval)
+	if !ok || seconds <= 0 || seconds > 60 {
+		return "", fmt.Errorf("invalid duration for 'probeInterval': %q, must be greater than 0 and at most 1m", interval)
+	}
+
+	return interval, nil
+}
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
@@ -0,0 +1,134 @@
+package probelatency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	probelatency "github.com/lokalise/common-sloth-sli-plugins/plugins/probe-latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without anything provided, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"Without ingress label value, should fail.": {
+			options: map[string]string{
+				"ingressLabelName": "ingress",
+				"latencyThreshold": "0.5",
+			},
+			expErr: true,
+		},
+
+		"Without latency threshold, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+			},
+			expErr: true,
+		},
+
+		"An invalid latency threshold, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+				"latencyThreshold":  "500ms",
+			},
+			expErr: true,
+		},
+
+		"An unknown phase, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+				"latencyThreshold":  "0.5",
+				"phase":             "handshake",
+			},
+			expErr: true,
+		},
+
+		"A probe interval longer than a minute, should fail.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+				"latencyThreshold":  "0.5",
+				"probeInterval":     "2m",
+			},
+			expErr: true,
+		},
+
+		"Probe interval provided should read the probes of every minute at that step.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+				"latencyThreshold":  "0.5",
+				"probeInterval":     "30s",
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time((probe_duration_seconds{ingress=~"expert-api-ingress-internal"} > bool 0.5)[1m:30s])
+	)[{{ .window }}:1m]
+)) OR on() vector(0)
+`,
+		},
+
+		"Latency threshold provided should count the fraction of slower probes.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "expert-api-ingress-internal",
+				"latencyThreshold":  "0.5",
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time((probe_duration_seconds{ingress=~"expert-api-ingress-internal"} > bool 0.5)[1m:15s])
+	)[{{ .window }}:1m]
+)) OR on() vector(0)
+`,
+		},
+
+		"Phase provided should use the duration of that phase.": {
+			options: map[string]string{
+				"ingressLabelName":  "ingress",
+				"ingresses":         "api-ingress,app-ingress",
+				"latencyThreshold":  "0.2",
+				"phase":             "tls",
+				"additionalLabels":  `env="live"`,
+				"maintenanceSeries": `maintenance_mode{app="api"}`,
+				"offset":            "5m",
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time((probe_http_duration_seconds{env="live", ingress=~"api-ingress|app-ingress", phase="tls"} > bool 0.2)[1m:15s]) unless on() (maintenance_mode{app="api"})
+	)[{{ .window }}:1m] offset 5m
+)) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := probelatency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/redis/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"An invalid instance regex, should fail.": {
+			options: map[string]string{"instance_regex": "([xyz"},
+			expErr:  true,
+		},
+
+		"Both command regex and command list provided, should fail.": {
+			options: map[string]string{
+				"command_regex": "get|set",
+				"commands":      "get,set",
+			},
+			expErr: true,
+		},
+
+		"Without options should combine the downtime of all instances with the failed commands.": {
+			options: map[string]string{},
+			expQuery: `
+1 - (
+	(1 - (
+		max(avg_over_time(
+			(redis_up{ instance=~".*" } == bool 0)[{{ .window }}:1m]
+		)) OR on() vector(0)
+	))
+	*
+	(1 - (
+		sum(
+			rate(redis_commands_failed_calls_total{ instance=~".*", cmd=~".*" }[{{ .window }}])
+		)
+		/
+		(sum(
+			rate(redis_commands_total{ instance=~".*", cmd=~".*" }[{{ .window }}])
+		) > 0) OR on() vector(0)
+	))
+) OR on() vector(0)
+`,
+		},
+
+		"Instance, commands, filter, maintenance series and offset provided should be applied.": {
+			options: map[string]string{
+				"instance_regex":     "redis-cache-.*",
+				"commands":           "get,set",
+				"filter":             `{env="live"}`,
+				"maintenance_series": `maintenance_mode{app="redis"}`,
+				"offset":             "5m",
+			},
+			expQuery: `
+1 - (
+	(1 - (
+		max(avg_over_time(
+			(redis_up{ env="live",instance=~"redis-cache-.*" } == bool 0 unless on() (maintenance_mode{app="redis"}))[{{ .window }}:1m] offset 5m
+		)) OR on() vector(0)
+	))
+	*
+	(1 - (
+		sum_over_time((sum(
+			rate(redis_commands_failed_calls_total{ env="live",instance=~"redis-cache-.*", cmd=~"get|set" }[5m])
+		) unless on() (maintenance_mode{app="redis"}))[{{ .window }}:1m] offset 5m)
+		/
+		(sum_over_time((sum(
+			rate(redis_commands_total{ env="live",instance=~"redis-cache-.*", cmd=~"get|set" }[5m])
+		) unless on() (maintenance_mode{app="redis"}))[{{ .window }}:1m] offset 5m) > 0) OR on() vector(0)
+	))
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
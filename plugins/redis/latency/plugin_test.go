@@ -0,0 +1,91 @@
+package latency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	latency "github.com/lokalise/common-sloth-sli-plugins/plugins/redis/latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without bucket, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An invalid bucket, should fail.": {
+			options: map[string]string{"bucket": "1ms"},
+			expErr:  true,
+		},
+
+		"A bucket that is not a power of two in microseconds, should fail.": {
+			options: map[string]string{"bucket": "0.001"},
+			expErr:  true,
+		},
+
+		"A negative bucket, should fail.": {
+			options: map[string]string{"bucket": "-0.001"},
+			expErr:  true,
+		},
+
+		"Bucket provided in seconds should be converted to microseconds.": {
+			options: map[string]string{"bucket": "0.001024"},
+			expQuery: `
+1 - (
+	sum(
+		rate(redis_commands_latencies_usec_bucket{ instance=~".*", cmd=~".*", le="1024" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(redis_commands_latencies_usec_count{ instance=~".*", cmd=~".*" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Instance, command regex, filter, maintenance series and offset provided should be applied.": {
+			options: map[string]string{
+				"bucket":             "0.000256",
+				"instance_regex":     "redis-cache-.*",
+				"command_regex":      "(get|mget)",
+				"filter":             `{env="live"}`,
+				"maintenance_series": `maintenance_mode{app="redis"}`,
+				"offset":             "5m",
+			},
+			expQuery: `
+1 - (
+	sum_over_time((sum(
+		rate(redis_commands_latencies_usec_bucket{ env="live",instance=~"redis-cache-.*", cmd=~"(get|mget)", le="256" }[5m])
+	) unless on() (maintenance_mode{app="redis"}))[{{ .window }}:1m] offset 5m)
+	/
+	(sum_over_time((sum(
+		rate(redis_commands_latencies_usec_count{ env="live",instance=~"redis-cache-.*", cmd=~"(get|mget)" }[5m])
+	) unless on() (maintenance_mode{app="redis"}))[{{ .window }}:1m] offset 5m) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := latency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/spanmetrics/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An invalid span name query, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"span_name_regex":    "([xyz",
+			},
+			expErr: true,
+		},
+
+		"An unknown span kind, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"span_kind":          "SERVER",
+			},
+			expErr: true,
+		},
+
+		"With service name should return a valid query on server spans.": {
+			options: map[string]string{"service_name_regex": "files-api"},
+			expQuery: `
+(
+	sum(
+		rate(traces_spanmetrics_calls_total{ service_name=~"files-api", span_name=~".*", span_kind="SPAN_KIND_SERVER", status_code="STATUS_CODE_ERROR" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traces_spanmetrics_calls_total{ service_name=~"files-api", span_name=~".*", span_kind="SPAN_KIND_SERVER" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Span name, span kind and filter provided should return a valid query.": {
+			options: map[string]string{
+				"filter":          `deployment_environment="live"`,
+				"services":        "files-api",
+				"span_name_regex": "POST /v1/.*",
+				"span_kind":       "SPAN_KIND_CONSUMER",
+			},
+			expQuery: `
+(
+	sum(
+		rate(traces_spanmetrics_calls_total{ deployment_environment="live",service_name=~"files-api", span_name=~"POST /v1/.*", span_kind="SPAN_KIND_CONSUMER", status_code="STATUS_CODE_ERROR" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traces_spanmetrics_calls_total{ deployment_environment="live",service_name=~"files-api", span_name=~"POST /v1/.*", span_kind="SPAN_KIND_CONSUMER" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package latency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	latency "github.com/lokalise/common-sloth-sli-plugins/plugins/spanmetrics/latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{"bucket": "0.5"},
+			expErr:  true,
+		},
+
+		"Without bucket, should fail.": {
+			options: map[string]string{"service_name_regex": "files-api"},
+			expErr:  true,
+		},
+
+		"A non numeric bucket, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"bucket":             "500ms",
+			},
+			expErr: true,
+		},
+
+		"With service name and bucket should return a valid query on server spans.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"bucket":             "0.5",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(traces_spanmetrics_latency_bucket{ service_name=~"files-api", span_name=~".*", span_kind="SPAN_KIND_SERVER", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traces_spanmetrics_latency_count{ service_name=~"files-api", span_name=~".*", span_kind="SPAN_KIND_SERVER" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"A metric name of another span metrics generator should be used.": {
+			options: map[string]string{
+				"service_name_regex": "files-api",
+				"span_name_regex":    "GET /v1/.*",
+				"bucket":             "0.5",
+				"metric_name":        "traces_span_metrics_duration_seconds_bucket",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(traces_span_metrics_duration_seconds_bucket{ service_name=~"files-api", span_name=~"GET /v1/.*", span_kind="SPAN_KIND_SERVER", le="0.5" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traces_span_metrics_duration_seconds_count{ service_name=~"files-api", span_name=~"GET /v1/.*", span_kind="SPAN_KIND_SERVER" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := latency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package availability_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	availability "github.com/lokalise/common-sloth-sli-plugins/plugins/traefik/availability"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{},
+			expErr:  true,
+		},
+
+		"An invalid service name query, should fail.": {
+			options: map[string]string{"service_name_regex": "([xyz"},
+			expErr:  true,
+		},
+
+		"An invalid entrypoint query, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"entrypoint_regex":   "([xyz",
+			},
+			expErr: true,
+		},
+
+		"A service name without provider should match any provider.": {
+			options: map[string]string{
+				"service_name_regex": "files-api.*",
+			},
+			expQuery: `
+(
+	sum(
+		rate(traefik_service_requests_total{ service=~"(files-api.*)(@.*)?", code=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traefik_service_requests_total{ service=~"(files-api.*)(@.*)?" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"A service name with provider should be used as is.": {
+			options: map[string]string{
+				"filter":             `k1="v1"`,
+				"service_name_regex": "files-api-80@kubernetes",
+				"entrypoint_regex":   "websecure",
+				"status_regex":       "5..",
+			},
+			expQuery: `
+(
+	sum(
+		rate(traefik_service_requests_total{ k1="v1",service=~"files-api-80@kubernetes", entrypoint=~"websecure", code=~"5.." }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traefik_service_requests_total{ k1="v1",service=~"files-api-80@kubernetes", entrypoint=~"websecure" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"A list of services should match every service with any provider.": {
+			options: map[string]string{
+				"services": "files-api, export-api@file",
+			},
+			expQuery: `
+(
+	sum(
+		rate(traefik_service_requests_total{ service=~"(files-api)(@.*)?|export-api@file", code=~"(5..|429|431)" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traefik_service_requests_total{ service=~"(files-api)(@.*)?|export-api@file" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := availability.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
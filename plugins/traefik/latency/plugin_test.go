@@ -0,0 +1,89 @@
+package latency_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	latency "github.com/lokalise/common-sloth-sli-plugins/plugins/traefik/latency"
+)
+
+func TestSLIPlugin(t *testing.T) {
+	tests := map[string]struct {
+		meta     map[string]string
+		labels   map[string]string
+		options  map[string]string
+		expQuery string
+		expErr   bool
+	}{
+		"Without service name, should fail.": {
+			options: map[string]string{"bucket": "0.3"},
+			expErr:  true,
+		},
+
+		"Without bucket, should fail.": {
+			options: map[string]string{"service_name_regex": "test"},
+			expErr:  true,
+		},
+
+		"A non numeric bucket, should fail.": {
+			options: map[string]string{
+				"service_name_regex": "test",
+				"bucket":             "300ms",
+			},
+			expErr: true,
+		},
+
+		"With service name and bucket should return a valid query.": {
+			options: map[string]string{
+				"service_name_regex": "files-api.*",
+				"bucket":             "0.3",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(traefik_service_request_duration_seconds_bucket{ service=~"(files-api.*)(@.*)?", le="0.3" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traefik_service_request_duration_seconds_count{ service=~"(files-api.*)(@.*)?" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+
+		"Entrypoint and a service with provider should be used as is.": {
+			options: map[string]string{
+				"service_name_regex": "files-api-80@kubernetes",
+				"entrypoint_regex":   "websecure",
+				"bucket":             "0.3",
+			},
+			expQuery: `
+1 - (
+	sum(
+		rate(traefik_service_request_duration_seconds_bucket{ service=~"files-api-80@kubernetes", entrypoint=~"websecure", le="0.3" }[{{ .window }}])
+	)
+	/
+	(sum(
+		rate(traefik_service_request_duration_seconds_count{ service=~"files-api-80@kubernetes", entrypoint=~"websecure" }[{{ .window }}])
+	) > 0)
+) OR on() vector(0)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			gotQuery, err := latency.SLIPlugin(context.TODO(), test.meta, test.labels, test.options)
+
+			if test.expErr {
+				assert.Error(err)
+			} else if assert.NoError(err) {
+				assert.Equal(test.expQuery, gotQuery)
+			}
+		})
+	}
+}
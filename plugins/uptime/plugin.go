@@ -17,26 +17,41 @@ const (
 var queryTpl = template.Must(template.New("").Option("missingkey=error").Parse(`
 max(avg_over_time(
 	(
-		avg_over_time({{ .metricName }}{{"{"}}{{ .additionalLabels }}{{ .ingressLabelName }}=~"{{ .ingressLabelValue }}"{{"}"}}[1m]) <= bool 0.25
-	)[{{"{{ .window }}"}}:1m]
+		avg_over_time({{ .metricName }}{{"{"}}{{ .additionalLabels }}{{ .ingressLabelName }}=~"{{ .ingressLabelValue }}"{{"}"}}[1m]) <= bool 0.25{{ .maintenance }}
+	)[{{"{{ .window }}"}}:1m]{{ .offset }}
 )) OR on() vector(0)
 `))
 
-// SLIPlugin will return a query that will return the availability error based on traefik V1 ingress metrics.
+// SLIPlugin will return a query that will return the downtime error based on blackbox exporter probe metrics.
 func SLIPlugin(ctx context.Context, meta, labels, options map[string]string) (string, error) {
 	metricName, err := getMetricName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	ingressLabelName, err := getIngressLabelName(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	ingressLabelValue, err := getIngressLabelValue(options)
 	if err != nil {
 		return "", fmt.Errorf("Error parsing options: %w", err)
 	}
 
+	offset, err := getOffset(options)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing options: %w", err)
+	}
+
 	var b bytes.Buffer
 	data := map[string]string{
 		"metricName":        metricName,
 		"ingressLabelName":  ingressLabelName,
 		"ingressLabelValue": ingressLabelValue,
 		"additionalLabels":  getAdditionalLabels(options),
+		"maintenance":       getMaintenanceFilter(options),
+		"offset":            offset,
 	}
 	err = queryTpl.Execute(&b, data)
 	if err != nil {
@@ -72,6 +87,17 @@ func getIngressLabelValue(options map[string]string) (string, error) {
 	value := options["ingressLabelValue"]
 	value = strings.TrimSpace(value)
 
+	values := options["ingresses"]
+	values = strings.TrimSpace(values)
+
+	if value != "" && values != "" {
+		return "", fmt.Errorf("only one of 'ingressLabelValue' and 'ingresses' can be set")
+	}
+
+	if values != "" {
+		return getExactMatchRegex("ingresses", values)
+	}
+
 	if value == "" {
 		return "", fmt.Errorf("'ingressLabelValue' is required")
 	}
@@ -92,3 +118,51 @@ func getMetricName(options map[string]string) (string, error) {
 
 	return metricName, nil
 }
+
+// getMaintenanceFilter drops the time slices in which the maintenance series is present.
+func getMaintenanceFilter(options map[string]string) string {
+	maintenance := options["maintenanceSeries"]
+	maintenance = strings.TrimSpace(maintenance)
+
+	if maintenance == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(" unless on() (%s)", maintenance)
+}
+
+var durationRegexp = regexp.MustCompile(`^(([0-9]+)y)?(([0-9]+)w)?(([0-9]+)d)?(([0-9]+)h)?(([0-9]+)m)?(([0-9]+)s)?(([0-9]+)ms)?$`)
+
+func getOffset(options map[string]string) (string, error) {
+	offset := options["offset"]
+	offset = strings.TrimSpace(offset)
+
+	if offset == "" {
+		return "", nil
+	}
+
+	if !durationRegexp.MatchString(offset) {
+		return "", fmt.Errorf("invalid duration for 'offset': %q", offset)
+	}
+
+	return " offset " + offset, nil
+}
+
+// getExactMatchRegex quotes every value of a comma separated list and joins them into an alternation that only
+// matches those values. Backslashes and quotes are escaped so the result can be used inside a PromQL string.
+func getExactMatchRegex(key, list string) (string, error) {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", fmt.Errorf("'%s' contains an empty value", key)
+		}
+
+		value = regexp.QuoteMeta(value)
+		value = strings.ReplaceAll(value, `\`, `\\`)
+		value = strings.ReplaceAll(value, `"`, `\"`)
+		values = append(values, value)
+	}
+
+	return strings.Join(values, "|"), nil
+}
@@ -60,6 +60,82 @@ max(avg_over_time(
 		avg_over_time(probe_success{instance=~".*", ingress=~"test"}[1m]) <= bool 0.25
 	)[{{ .window }}:1m]
 )) OR on() vector(0)
+`,
+		},
+
+		"Maintenance series provided should drop time slices with active maintenance.": {
+			options: map[string]string{
+				"metricName":        "probe_success",
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "test",
+				"maintenanceSeries": `maintenance_active{service="test"} == 1`,
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time(probe_success{ingress=~"test"}[1m]) <= bool 0.25 unless on() (maintenance_active{service="test"} == 1)
+	)[{{ .window }}:1m]
+)) OR on() vector(0)
+`,
+		},
+
+		"An invalid offset, should fail.": {
+			options: map[string]string{
+				"metricName":        "probe_success",
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "test",
+				"offset":            "5 minutes",
+			},
+			expErr: true,
+		},
+
+		"Offset provided should shift the whole subquery.": {
+			options: map[string]string{
+				"metricName":        "probe_success",
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "test",
+				"offset":            "5m",
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time(probe_success{ingress=~"test"}[1m]) <= bool 0.25
+	)[{{ .window }}:1m] offset 5m
+)) OR on() vector(0)
+`,
+		},
+
+		"Both a regex and a list of ingresses, should fail.": {
+			options: map[string]string{
+				"metricName":        "probe_success",
+				"ingressLabelName":  "ingress",
+				"ingressLabelValue": "test",
+				"ingresses":         "api.v1,web",
+			},
+			expErr: true,
+		},
+
+		"A list of ingresses with an empty value, should fail.": {
+			options: map[string]string{
+				"metricName":       "probe_success",
+				"ingressLabelName": "ingress",
+				"ingresses":        "api.v1,,web",
+			},
+			expErr: true,
+		},
+
+		"A list of ingresses should be escaped and joined into an exact alternation.": {
+			options: map[string]string{
+				"metricName":       "probe_success",
+				"ingressLabelName": "ingress",
+				"ingresses":        "api.v1, web",
+			},
+			expQuery: `
+max(avg_over_time(
+	(
+		avg_over_time(probe_success{ingress=~"api\\.v1|web"}[1m]) <= bool 0.25
+	)[{{ .window }}:1m]
+)) OR on() vector(0)
 `,
 		},
 	}